@@ -7,6 +7,7 @@ import (
 	_ "embed"
 	"encoding/json"
 	"errors"
+	"flag"
 	"fmt"
 	"os"
 	"os/exec"
@@ -27,15 +28,30 @@ func main() {
 	usage := func() {
 		sg.Logger(ctx).Println(`Usage:
 	init
-		to initialize sage`)
+		to initialize sage
+	tools list
+		to list the tools in the tool lockfile
+	tools verify
+		to verify the checksums of the tools in the tool lockfile
+	tools lock [-update]
+		to verify the checksums of the tools in the tool lockfile, and with -update to record changed checksums`)
 		os.Exit(0)
 	}
-	if len(os.Args) < 2 || len(os.Args) > 2 {
-		usage()
-	}
-	switch os.Args[1] {
-	case "init":
+	switch {
+	case len(os.Args) == 2 && os.Args[1] == "init":
 		initSage(ctx)
+	case len(os.Args) == 3 && os.Args[1] == "tools" && os.Args[2] == "list":
+		listTools(ctx)
+	case len(os.Args) == 3 && os.Args[1] == "tools" && os.Args[2] == "verify":
+		verifyTools(ctx)
+	case len(os.Args) >= 3 && os.Args[1] == "tools" && os.Args[2] == "lock":
+		flags := flag.NewFlagSet("tools lock", flag.ExitOnError)
+		update := flags.Bool("update", false, "record changed checksums")
+		_ = flags.Parse(os.Args[3:])
+		if flags.NArg() > 0 {
+			usage()
+		}
+		lockTools(ctx, *update)
 	default:
 		usage()
 	}
@@ -0,0 +1,164 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// AnnotationLevel is the severity of an Annotation.
+type AnnotationLevel string
+
+const (
+	// AnnotationError is a problem that fails the tool.
+	AnnotationError AnnotationLevel = "error"
+	// AnnotationWarning is a problem that should be fixed.
+	AnnotationWarning AnnotationLevel = "warning"
+	// AnnotationNotice is a suggestion.
+	AnnotationNotice AnnotationLevel = "notice"
+)
+
+// Annotation is a problem in a file, reported by a tool.
+type Annotation struct {
+	// Level of the problem, defaults to AnnotationError.
+	Level AnnotationLevel
+	// File with the problem, relative to the working directory of the tool, or absolute.
+	File string
+	// Line of the problem, or zero for the whole file.
+	Line int
+	// Column of the problem, or zero for the whole line.
+	Column int
+	// Message describing the problem.
+	Message string
+}
+
+// AnnotationRule parses a line of tool output into an Annotation, and reports if the line is one.
+type AnnotationRule func(line string) (Annotation, bool)
+
+//nolint:gochecknoglobals
+var gccAnnotationRegexp = regexp.MustCompile(
+	`^\s*([^\s:][^:]*):(\d+):(?:(\d+):)?\s*(?:(fatal error|error|warning|note|info|style):\s*)?(.+)$`,
+)
+
+// GCCAnnotationRule parses lines in the format path:line:column: [level:] message, as printed by the Go toolchain,
+// golangci-lint, GCC and shellcheck --format=gcc.
+//
+// Since many tools print file references in this format without reporting a problem, such as go test, tools opt in to
+// the rule with WithAnnotationRules.
+func GCCAnnotationRule(line string) (Annotation, bool) {
+	match := gccAnnotationRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return Annotation{}, false
+	}
+	result := Annotation{File: match[1], Message: match[5], Level: toAnnotationLevel(match[4])}
+	result.Line, _ = strconv.Atoi(match[2])
+	result.Column, _ = strconv.Atoi(match[3])
+	return result, true
+}
+
+// toAnnotationLevel converts the severity printed by a tool to an AnnotationLevel.
+func toAnnotationLevel(severity string) AnnotationLevel {
+	switch severity {
+	case "warning":
+		return AnnotationWarning
+	case "note", "info", "style":
+		return AnnotationNotice
+	default:
+		return AnnotationError
+	}
+}
+
+type annotationRulesContextKey struct{}
+
+// WithAnnotationRules attaches rules for the output format of a tool to the provided context.
+//
+// When running in GitHub Actions, the output of commands created with Command is parsed with the rules, and the
+// problems found are reported as GitHub Actions annotations. The output of commands without rules is not parsed.
+func WithAnnotationRules(ctx context.Context, rules ...AnnotationRule) context.Context {
+	return context.WithValue(ctx, annotationRulesContextKey{}, append(getAnnotationRules(ctx), rules...))
+}
+
+func getAnnotationRules(ctx context.Context) []AnnotationRule {
+	rules, _ := ctx.Value(annotationRulesContextKey{}).([]AnnotationRule)
+	return rules[:len(rules):len(rules)]
+}
+
+// parseAnnotation parses a line of output from a command running in dir, and reports if the line is an Annotation
+// of an existing file. The file of the Annotation is made relative to the git root.
+func parseAnnotation(rules []AnnotationRule, dir, line string) (Annotation, bool) {
+	for _, rule := range rules {
+		annotation, ok := rule(line)
+		if !ok {
+			continue
+		}
+		file, ok := annotationFile(dir, annotation.File)
+		if !ok {
+			continue
+		}
+		annotation.File = file
+		return annotation, true
+	}
+	return Annotation{}, false
+}
+
+// annotationFile returns the path of an existing file relative to the git root.
+func annotationFile(dir, file string) (string, bool) {
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(dir, file)
+	}
+	if info, err := os.Stat(file); err != nil || info.IsDir() {
+		return "", false
+	}
+	result, err := filepath.Rel(FromGitRoot(), file)
+	if err != nil {
+		return "", false
+	}
+	return filepath.ToSlash(result), true
+}
+
+// LogAnnotation logs a problem in a file found by a tool, in the format path:line:column: message.
+// When running in GitHub Actions, the problem is also reported as a GitHub Actions annotation.
+// A relative file is relative to the git root.
+func LogAnnotation(ctx context.Context, annotation Annotation) {
+	Logger(ctx).Printf("%s:%d:%d: %s", annotation.File, annotation.Line, annotation.Column, annotation.Message)
+	if !isGitHubActions() {
+		return
+	}
+	if file, ok := annotationFile(FromGitRoot(), annotation.File); ok {
+		annotation.File = file
+	}
+	_, _ = fmt.Fprintln(outputWriter(ctx, os.Stderr), annotation.workflowCommand())
+}
+
+// workflowCommand returns the GitHub Actions workflow command that reports the annotation.
+func (a Annotation) workflowCommand() string {
+	level := a.Level
+	if level == "" {
+		level = AnnotationError
+	}
+	properties := []string{"file=" + escapeWorkflowProperty(a.File)}
+	if a.Line > 0 {
+		properties = append(properties, "line="+strconv.Itoa(a.Line))
+	}
+	if a.Column > 0 {
+		properties = append(properties, "col="+strconv.Itoa(a.Column))
+	}
+	return fmt.Sprintf("::%s %s::%s", level, strings.Join(properties, ","), escapeWorkflowData(a.Message))
+}
+
+func escapeWorkflowData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+func escapeWorkflowProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// isGitHubActions reports if the process runs in GitHub Actions.
+func isGitHubActions() bool {
+	return isTrue(os.Getenv("GITHUB_ACTIONS"))
+}
@@ -0,0 +1,102 @@
+package sg
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestGCCAnnotationRule(t *testing.T) {
+	for _, tt := range []struct {
+		line     string
+		expected Annotation
+		ok       bool
+	}{
+		{
+			line:     "main.go:10:2: undefined: foo",
+			expected: Annotation{Level: AnnotationError, File: "main.go", Line: 10, Column: 2, Message: "undefined: foo"},
+			ok:       true,
+		},
+		{
+			line: "script.sh:3:8: warning: Double quote to prevent globbing. [SC2086]",
+			expected: Annotation{
+				Level:   AnnotationWarning,
+				File:    "script.sh",
+				Line:    3,
+				Column:  8,
+				Message: "Double quote to prevent globbing. [SC2086]",
+			},
+			ok: true,
+		},
+		{
+			line:     "script.sh:1: note: Use $(...) notation.",
+			expected: Annotation{Level: AnnotationNotice, File: "script.sh", Line: 1, Message: "Use $(...) notation."},
+			ok:       true,
+		},
+		{line: "no file reference"},
+	} {
+		tt := tt
+		t.Run(tt.line, func(t *testing.T) {
+			actual, ok := GCCAnnotationRule(tt.line)
+			if ok != tt.ok || actual != tt.expected {
+				t.Errorf("expected %v, %v but got %v, %v", tt.expected, tt.ok, actual, ok)
+			}
+		})
+	}
+}
+
+func TestParseAnnotation(t *testing.T) {
+	rules := []AnnotationRule{GCCAnnotationRule}
+	annotation, ok := parseAnnotation(rules, FromGitRoot("sg"), "annotation.go:1:1: message")
+	if !ok || annotation.File != "sg/annotation.go" {
+		t.Errorf("expected annotation of sg/annotation.go but got %v, %v", annotation, ok)
+	}
+	if _, ok := parseAnnotation(rules, FromGitRoot("sg"), "missing.go:1:1: message"); ok {
+		t.Error("expected no annotation of a missing file")
+	}
+	if _, ok := parseAnnotation(nil, FromGitRoot("sg"), "annotation.go:1:1: message"); ok {
+		t.Error("expected no annotation without rules")
+	}
+}
+
+func TestAnnotation_WorkflowCommand(t *testing.T) {
+	annotation := Annotation{File: "a,b.go", Line: 1, Message: "100% wrong\nreally"}
+	const expected = "::error file=a%2Cb.go,line=1::100%25 wrong%0Areally"
+	if actual := annotation.workflowCommand(); actual != expected {
+		t.Errorf("expected %s but got %s", expected, actual)
+	}
+}
+
+func TestCommand_Annotations(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "true")
+	ctx := WithAnnotationRules(WithLogger(context.Background(), NewLogger("test")), GCCAnnotationRule)
+	cmd := Command(ctx, "echo", "annotation.go:3:1: warning: message")
+	cmd.Dir = FromGitRoot("sg")
+	var stdout bytes.Buffer
+	cmd.Stdout = newLogWriter(ctx, cmd, &stdout)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "[test] annotation.go:3:1: warning: message\n::warning file=sg/annotation.go,line=3,col=1::message\n"
+	if !strings.HasSuffix(stdout.String(), expected) {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, stdout.String())
+	}
+}
+
+func TestCommand_AnnotationsNoMatch(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "true")
+	ctx := WithAnnotationRules(WithLogger(context.Background(), NewLogger("test")), GCCAnnotationRule)
+	cmd := Command(ctx, "echo", "  annotation.go: message")
+	cmd.Dir = FromGitRoot("sg")
+	var stdout bytes.Buffer
+	cmd.Stdout = newLogWriter(ctx, cmd, &stdout)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	// Lines that don't match a rule are written as without GitHub Actions, with file references unprefixed.
+	const expected = "\nannotation.go: message\n"
+	if !strings.HasSuffix(stdout.String(), expected) || strings.Contains(stdout.String(), "::") {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, stdout.String())
+	}
+}
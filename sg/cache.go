@@ -0,0 +1,198 @@
+package sg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CacheConfig configures which files are fingerprinted by a cached Target.
+type CacheConfig struct {
+	// Inputs are glob patterns, relative to the git root, of files read by the target.
+	// In addition to the patterns supported by path.Match, a "**" path element matches any number of directories.
+	Inputs []string
+	// Outputs are paths, relative to the git root, of files or directories produced by the target.
+	Outputs []string
+}
+
+// WithCache returns a Target that is skipped when its inputs and outputs are unchanged since its last successful run.
+//
+// Fingerprints are stored in the build directory, so a clean-sage invalidates all cached targets.
+// Setting the environment variable SAGE_DISABLE_CACHE to true forces all cached targets to run.
+func WithCache(target interface{}, config CacheConfig) Target {
+	return cachedTarget{Target: checkFunctions(target)[0], config: config}
+}
+
+type cachedTarget struct {
+	Target
+	config CacheConfig
+}
+
+// Unwrap returns the wrapped Target.
+func (c cachedTarget) Unwrap() Target {
+	return c.Target
+}
+
+// Run implements Target.
+func (c cachedTarget) Run(ctx context.Context) error {
+	fingerprintFile := FromBuildDir("cache", fmt.Sprintf("%x", sha256.Sum256([]byte(c.ID()))))
+	if disableCache, ok := os.LookupEnv("SAGE_DISABLE_CACHE"); !ok || !isTrue(disableCache) {
+		previous, err := os.ReadFile(fingerprintFile)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("read fingerprint: %w", err)
+		}
+		if len(previous) > 0 {
+			current, err := c.fingerprint()
+			if err != nil {
+				return err
+			}
+			if string(previous) == current {
+				Logger(ctx).Println("skipping, inputs and outputs are unchanged")
+				return nil
+			}
+		}
+	}
+	// Remove the fingerprint before running, so that a failed run is never treated as up to date.
+	if err := os.Remove(fingerprintFile); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("remove fingerprint: %w", err)
+	}
+	if err := c.Target.Run(ctx); err != nil {
+		return err
+	}
+	current, err := c.fingerprint()
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(fingerprintFile, []byte(current), 0o600); err != nil {
+		return fmt.Errorf("write fingerprint: %w", err)
+	}
+	return nil
+}
+
+// fingerprint returns a hash of the target ID together with the paths and contents of all inputs and outputs.
+func (c cachedTarget) fingerprint() (string, error) {
+	h := sha256.New()
+	_, _ = fmt.Fprintf(h, "id %s\n", c.ID())
+	root := FromGitRoot()
+	inputs, err := globFiles(root, c.config.Inputs)
+	if err != nil {
+		return "", fmt.Errorf("fingerprint inputs: %w", err)
+	}
+	for _, input := range inputs {
+		if err := hashFile(h, "input", root, input); err != nil {
+			return "", err
+		}
+	}
+	for _, output := range c.config.Outputs {
+		outputPath := filepath.Join(root, output)
+		if _, err := os.Stat(outputPath); err != nil {
+			// A missing output always invalidates the fingerprint.
+			_, _ = fmt.Fprintf(h, "missing %s\n", output)
+			continue
+		}
+		if err := filepath.WalkDir(outputPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			return hashFile(h, "output", root, path)
+		}); err != nil {
+			return "", fmt.Errorf("fingerprint outputs: %w", err)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, kind, root, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("hash %s: %w", path, err)
+	}
+	defer f.Close()
+	fileHash := sha256.New()
+	if _, err := io.Copy(fileHash, f); err != nil {
+		return fmt.Errorf("hash %s: %w", path, err)
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return fmt.Errorf("hash %s: %w", path, err)
+	}
+	_, _ = fmt.Fprintf(w, "%s %s %x\n", kind, filepath.ToSlash(rel), fileHash.Sum(nil))
+	return nil
+}
+
+// globFiles returns the sorted paths of all regular files below root matching any of the patterns.
+func globFiles(root string, patterns []string) ([]string, error) {
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+	var result []string
+	if err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			switch rel {
+			case ".git", path.Join(sageDir, toolsDir), path.Join(sageDir, binDir), path.Join(sageDir, buildDir):
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		for _, pattern := range patterns {
+			if matchGlob(pattern, rel) {
+				result = append(result, p)
+				break
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// matchGlob reports whether the slash-separated name matches the pattern, where a "**" element matches zero or
+// more path elements.
+func matchGlob(pattern, name string) bool {
+	return matchGlobElems(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobElems(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchGlobElems(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,170 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func Test_matchGlob(t *testing.T) {
+	for _, tt := range []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{pattern: "go.mod", name: "go.mod", expected: true},
+		{pattern: "*.go", name: "main.go", expected: true},
+		{pattern: "*.go", name: "sg/main.go", expected: false},
+		{pattern: "**/*.go", name: "main.go", expected: true},
+		{pattern: "**/*.go", name: "sg/internal/runner/runner.go", expected: true},
+		{pattern: "proto/**/*.proto", name: "proto/einride/v1/api.proto", expected: true},
+		{pattern: "proto/**/*.proto", name: "api/einride/v1/api.proto", expected: false},
+		{pattern: "proto/**", name: "proto/buf.yaml", expected: true},
+		{pattern: "**/*.proto", name: "main.go", expected: false},
+	} {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			if actual := matchGlob(tt.pattern, tt.name); actual != tt.expected {
+				t.Errorf("expected %v but got %v", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestWithCache(t *testing.T) {
+	repo := withTestRepo(t)
+	writeCacheTestFile(t, repo, "main.go", "package main\n")
+	var runs int
+	target := WithCache(func(ctx context.Context) error {
+		runs++
+		writeCacheTestFile(t, repo, "build/out", "out\n")
+		return nil
+	}, CacheConfig{Inputs: []string{"**/*.go"}, Outputs: []string{"build"}})
+	ctx := context.Background()
+	expectRuns := func(expected int) {
+		t.Helper()
+		if err := target.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if runs != expected {
+			t.Fatalf("expected %d runs but got %d", expected, runs)
+		}
+	}
+	expectRuns(1)
+	// Unchanged inputs and outputs are a hit.
+	expectRuns(1)
+	// Changed, added and removed inputs are a miss.
+	writeCacheTestFile(t, repo, "main.go", "package main\n\nfunc main() {}\n")
+	expectRuns(2)
+	expectRuns(2)
+	writeCacheTestFile(t, repo, "cmd/tool/main.go", "package main\n")
+	expectRuns(3)
+	if err := os.Remove(filepath.Join(repo, "cmd", "tool", "main.go")); err != nil {
+		t.Fatal(err)
+	}
+	expectRuns(4)
+	// Files not matching the inputs are ignored.
+	writeCacheTestFile(t, repo, "README.md", "# repo\n")
+	expectRuns(4)
+	// Changed and missing outputs are a miss.
+	writeCacheTestFile(t, repo, "build/out", "changed\n")
+	expectRuns(5)
+	if err := os.RemoveAll(filepath.Join(repo, "build")); err != nil {
+		t.Fatal(err)
+	}
+	expectRuns(6)
+	// The cache can be disabled.
+	t.Setenv("SAGE_DISABLE_CACHE", "true")
+	expectRuns(7)
+}
+
+func TestWithCache_key(t *testing.T) {
+	repo := withTestRepo(t)
+	writeCacheTestFile(t, repo, "main.go", "package main\n")
+	cacheTestRuns = map[string]int{}
+	config := CacheConfig{Inputs: []string{"*.go"}}
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		for _, arg := range []string{"a", "b"} {
+			// Targets with different arguments are cached separately.
+			if err := WithCache(Fn(cacheTestTarget, arg), config).Run(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if cacheTestRuns["a"] != 1 || cacheTestRuns["b"] != 1 {
+		t.Errorf("expected each target to run once, but got %v", cacheTestRuns)
+	}
+}
+
+func TestWithCache_failed(t *testing.T) {
+	repo := withTestRepo(t)
+	writeCacheTestFile(t, repo, "main.go", "package main\n")
+	var runs int
+	fail := true
+	target := WithCache(func(ctx context.Context) error {
+		runs++
+		if fail {
+			return fmt.Errorf("failed")
+		}
+		return nil
+	}, CacheConfig{Inputs: []string{"*.go"}})
+	ctx := context.Background()
+	if err := target.Run(ctx); err == nil {
+		t.Fatal("expected error")
+	}
+	// A failed run is never a hit.
+	fail = false
+	for i := 0; i < 2; i++ {
+		if err := target.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runs != 2 {
+		t.Errorf("expected 2 runs but got %d", runs)
+	}
+}
+
+var cacheTestRuns map[string]int
+
+func cacheTestTarget(_ context.Context, arg string) error {
+	cacheTestRuns[arg]++
+	return nil
+}
+
+// withTestRepo runs the test in a new git repository, and returns the repository.
+func withTestRepo(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	if output, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, output)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(repo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+	// Resolve symlinks in the path, such as of the temporary directory on macOS, as git does.
+	if repo, err = filepath.EvalSymlinks(repo); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func writeCacheTestFile(t *testing.T, repo, name, content string) {
+	t.Helper()
+	file := filepath.Join(repo, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,531 @@
+package sg
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"go/doc"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+const completionDir = "completion"
+
+// completionMakeDir is a directory with generated Makefiles, relative to the git root.
+type completionMakeDir struct {
+	dir     string
+	targets []completionTarget
+}
+
+// completionTarget is a make target or a sagefile target.
+type completionTarget struct {
+	names    []string
+	synopsis string
+	// args are the make variables or sagefile flags of the target, including the trailing "=".
+	args []string
+}
+
+// generateCompletions writes bash, zsh and fish completion scripts for make targets and sagefile arguments to the
+// build directory. The sagefile has the targets of the namespaces of all generated files.
+func generateCompletions(pkg *doc.Package, mks, namespaces []Makefile) error {
+	makeDirs, err := completionMakeDirs(pkg, mks)
+	if err != nil {
+		return err
+	}
+	sagefileTargets := completionSagefileTargets(pkg, namespaces)
+	root := FromGitRoot()
+	for _, script := range []struct {
+		filename string
+		generate func(*codegen.File, string, []completionMakeDir, []completionTarget)
+	}{
+		{filename: "sage.bash", generate: generateBashCompletion},
+		{filename: "sage.zsh", generate: generateZshCompletion},
+		{filename: "sage.fish", generate: generateFishCompletion},
+	} {
+		g := codegen.NewMakefile(codegen.FileConfig{GeneratedBy: "go.einride.tech/sage"})
+		script.generate(g, root, makeDirs, sagefileTargets)
+		if err := os.WriteFile(FromBuildDir(completionDir, script.filename), g.RawContent(), 0o600); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// completionMakeDirs returns the make targets of each directory with generated Makefiles.
+func completionMakeDirs(pkg *doc.Package, mks []Makefile) ([]completionMakeDir, error) {
+	targetsByDir := map[string][]completionTarget{}
+	for _, mk := range mks {
+		dir, err := filepath.Rel(FromGitRoot(), filepath.Dir(mk.Path))
+		if err != nil {
+			return nil, err
+		}
+		if dir == "." {
+			dir = ""
+		}
+		targets := []completionTarget{
+			{names: []string{makeHelpTarget(pkg, mk)}, synopsis: "List the targets of the Makefile."},
+			{names: []string{"sage"}, synopsis: "Build the sagefile and generate the Makefiles."},
+			{names: []string{"update-sage"}, synopsis: "Update Sage to the latest version."},
+			{names: []string{"clean-sage"}, synopsis: "Remove the Sage tools and build files."},
+		}
+		if mk.Completion {
+			targets = append(targets, completionTarget{
+				names:    []string{"sage-completion"},
+				synopsis: "Print the completion script for the current shell.",
+			})
+		}
+		forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+			if function.Recv != mk.namespaceName() {
+				return
+			}
+			target := completionTarget{
+				names:    []string{toMakeTarget(getTargetFunctionName(function))},
+				synopsis: doc.Synopsis(function.Doc),
+			}
+			for _, param := range targetParams(pkg, function) {
+				target.args = append(target.args, toMakeVar(param)+"=")
+			}
+			targets = append(targets, target, completionTarget{
+				names:    []string{toMakeWatchTarget(target.names[0])},
+				synopsis: "Run " + target.names[0] + " again whenever files change.",
+				args:     target.args,
+			})
+		})
+		if mk.namespaceName() == "" {
+			for _, i := range mks {
+				if i.namespaceName() != "" {
+					targets = append(targets, completionTarget{
+						names:    []string{toMakeTarget(i.namespaceName())},
+						synopsis: fmt.Sprintf("Run the default target of the %s Makefile.", i.namespaceName()),
+					})
+				}
+			}
+		}
+		targetsByDir[dir] = append(targetsByDir[dir], targets...)
+	}
+	result := make([]completionMakeDir, 0, len(targetsByDir))
+	for dir, targets := range targetsByDir {
+		result = append(result, completionMakeDir{dir: dir, targets: targets})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].dir < result[j].dir
+	})
+	return result, nil
+}
+
+// completionSagefileTargets returns the targets of the sagefile binary.
+func completionSagefileTargets(pkg *doc.Package, mks []Makefile) []completionTarget {
+	var result []completionTarget
+	names := map[string]bool{}
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if skipFunction, _ := shouldBeGenerated(mks, function.Recv); skipFunction {
+			names[getTargetFunctionName(function)] = true
+		}
+	})
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if skipFunction, _ := shouldBeGenerated(mks, function.Recv); !skipFunction {
+			return
+		}
+		target := completionTarget{
+			names:    []string{getTargetFunctionName(function)},
+			synopsis: doc.Synopsis(function.Doc),
+		}
+		// The sagefile also accepts the kebab-case name of the target, unless another target uses it.
+		if alias := toKebabTarget(getTargetFunctionName(function)); !names[alias] {
+			names[alias] = true
+			target.names = append(target.names, alias)
+		}
+		for _, param := range targetParams(pkg, function) {
+			target.args = append(target.args, "--"+toFlag(param)+"=")
+		}
+		result = append(result, target)
+	})
+	return result
+}
+
+func generateBashCompletion(
+	g *codegen.File, root string, makeDirs []completionMakeDir, sagefileTargets []completionTarget,
+) {
+	id := completionID(root)
+	g.P("# Completion of make targets and sagefile arguments for bash.")
+	g.P("# Load with: source <(make -s sage-completion)")
+	g.P("#")
+	g.P("# The script can be loaded for multiple repositories, and completes the targets of the repository of the current")
+	g.P("# directory, or of the directory given to make with -C. Make in other directories is completed as before.")
+	g.P()
+	g.P("_sage_make_words_", id, "() {")
+	g.P("\tcase \"$1\" in")
+	for _, makeDir := range makeDirs {
+		var names []string
+		for _, target := range makeDir.targets {
+			names = append(names, target.names...)
+		}
+		g.P("\t", shellQuoted(makeDir.dir), ") echo ", shellQuoted(strings.Join(names, " ")), " ;;")
+		for _, target := range makeDir.targets {
+			if len(target.args) == 0 {
+				continue
+			}
+			g.P(
+				"\t", shellQuoted(makeDir.dir+":"+target.names[0]), ") echo ",
+				shellQuoted(strings.Join(target.args, " ")), " ;;",
+			)
+		}
+	}
+	g.P("\tesac")
+	g.P("}")
+	g.P()
+	g.P("_sage_sagefile_words_", id, "() {")
+	g.P("\tcase \"$1\" in")
+	var names []string
+	for _, target := range sagefileTargets {
+		names = append(names, target.names...)
+	}
+	g.P("\t'') echo ", shellQuoted(strings.Join(append(sagefileOptions(), names...), " ")), " ;;")
+	for _, target := range sagefileTargets {
+		if len(target.args) == 0 {
+			continue
+		}
+		quoted := make([]string, 0, len(target.names))
+		for _, name := range target.names {
+			quoted = append(quoted, shellQuoted(name))
+		}
+		g.P("\t", strings.Join(quoted, " | "), ") echo ", shellQuoted(strings.Join(target.args, " ")), " ;;")
+	}
+	g.P("\tesac")
+	g.P("}")
+	g.P()
+	g.P("# _sage_lookup sets _sage_id to the ID of the loaded repository with the provided root.")
+	g.P("_sage_lookup() {")
+	g.P("\tlocal i")
+	g.P("\tfor ((i = 0; i < ${#_sage_roots[@]}; i++)); do")
+	g.P("\t\tif [[ ${_sage_roots[i]} == \"$1\" ]]; then")
+	g.P("\t\t\t_sage_id=${_sage_ids[i]}")
+	g.P("\t\t\treturn 0")
+	g.P("\t\tfi")
+	g.P("\tdone")
+	g.P("\treturn 1")
+	g.P("}")
+	g.P()
+	g.P("if ! _sage_lookup ", shellQuoted(root), "; then")
+	g.P("\t_sage_roots+=(", shellQuoted(root), ")")
+	g.P("\t_sage_ids+=(", id, ")")
+	g.P("fi")
+	g.P()
+	g.P("# _sage_line splits the command line up to the cursor on whitespace, since COMP_WORDS is also split on : and =.")
+	g.P("_sage_line() {")
+	g.P("\tlocal line=${COMP_LINE:0:COMP_POINT}")
+	g.P("\tread -ra _sage_args <<<\"$line\"")
+	g.P("\t_sage_cur=")
+	g.P("\tif [[ -n $line && $line != *[[:space:]] ]]; then")
+	g.P("\t\t_sage_cur=${_sage_args[${#_sage_args[@]} - 1]}")
+	g.P("\t\tunset \"_sage_args[${#_sage_args[@]} - 1]\"")
+	g.P("\tfi")
+	g.P("}")
+	g.P()
+	g.P("# _sage_reply completes the current word from the provided words.")
+	g.P("_sage_reply() {")
+	g.P("\tlocal broken=${COMP_WORDS[COMP_CWORD]}")
+	g.P("\tCOMPREPLY=($(compgen -W \"$1\" -- \"$_sage_cur\"))")
+	g.P("\t# Bash only replaces the part of the word after the last : or =.")
+	g.P("\tlocal prefix=${_sage_cur%\"$broken\"}")
+	g.P("\tif [[ -n $prefix && ${#COMPREPLY[@]} -gt 0 ]]; then")
+	g.P("\t\tCOMPREPLY=(\"${COMPREPLY[@]#\"$prefix\"}\")")
+	g.P("\tfi")
+	g.P("\tif [[ ${#COMPREPLY[@]} == 1 && ${COMPREPLY[0]} == *= ]] && type compopt &>/dev/null; then")
+	g.P("\t\tcompopt -o nospace 2>/dev/null")
+	g.P("\tfi")
+	g.P("}")
+	g.P()
+	g.P("# _sage_make_completion prints the completion function of make, unless it's the completion of this script.")
+	g.P("_sage_make_completion() {")
+	g.P("\tlocal spec")
+	g.P("\tspec=$(complete -p make 2>/dev/null)")
+	g.P("\tif [[ $spec =~ -F\\ ([^ ]+) && ${BASH_REMATCH[1]} != _sage_make ]]; then")
+	g.P("\t\techo \"${BASH_REMATCH[1]}\"")
+	g.P("\tfi")
+	g.P("}")
+	g.P()
+	g.P("# _sage_make_fallback completes make outside of loaded repositories, with the completion make had before.")
+	g.P("_sage_make_fallback() {")
+	g.P("\tif [[ -z $_sage_make_fallback ]] && declare -F _completion_loader >/dev/null; then")
+	g.P("\t\t# bash-completion loads the completion of make lazily, which replaces the completion of this script.")
+	g.P("\t\t_completion_loader make")
+	g.P("\t\t_sage_make_fallback=$(_sage_make_completion)")
+	g.P("\t\tcomplete -F _sage_make make")
+	g.P("\tfi")
+	g.P("\tif [[ -n $_sage_make_fallback ]] && declare -F \"$_sage_make_fallback\" >/dev/null; then")
+	g.P("\t\t\"$_sage_make_fallback\" \"$@\"")
+	g.P("\tfi")
+	g.P("}")
+	g.P()
+	g.P("_sage_make() {")
+	g.P("\tlocal dir=. root prefix words i")
+	g.P("\t_sage_line")
+	g.P("\tfor ((i = 1; i < ${#_sage_args[@]}; i++)); do")
+	g.P("\t\tif [[ ${_sage_args[i]} == -C ]]; then")
+	g.P("\t\t\tdir=${_sage_args[i + 1]}")
+	g.P("\t\tfi")
+	g.P("\tdone")
+	g.P("\troot=$(git -C \"$dir\" rev-parse --show-toplevel 2>/dev/null)")
+	g.P("\tif [[ -z $root ]] || ! _sage_lookup \"$root\"; then")
+	g.P("\t\t_sage_make_fallback \"$@\"")
+	g.P("\t\treturn")
+	g.P("\tfi")
+	g.P("\tif [[ ${_sage_args[${#_sage_args[@]} - 1]} == -C ]]; then")
+	g.P("\t\tCOMPREPLY=($(compgen -d -- \"$_sage_cur\"))")
+	g.P("\t\treturn")
+	g.P("\tfi")
+	g.P("\t# Don't complete the values of variables.")
+	g.P("\tif [[ $_sage_cur == *=* ]]; then")
+	g.P("\t\treturn")
+	g.P("\tfi")
+	g.P("\tprefix=$(git -C \"$dir\" rev-parse --show-prefix)")
+	g.P("\tprefix=${prefix%/}")
+	g.P("\twords=$(\"_sage_make_words_$_sage_id\" \"$prefix\")")
+	g.P("\tfor ((i = 1; i < ${#_sage_args[@]}; i++)); do")
+	g.P("\t\twords=\"$words $(\"_sage_make_words_$_sage_id\" \"$prefix:${_sage_args[i]}\")\"")
+	g.P("\tdone")
+	g.P("\t_sage_reply \"$words\"")
+	g.P("}")
+	g.P()
+	g.P("_sage_make_fallback=${_sage_make_fallback:-$(_sage_make_completion)}")
+	g.P("complete -F _sage_make make")
+	g.P()
+	g.P("_sage_sagefile() {")
+	g.P("\tlocal root target i")
+	g.P("\troot=$(git rev-parse --show-toplevel 2>/dev/null)")
+	g.P("\tif [[ -z $root ]] || ! _sage_lookup \"$root\"; then")
+	g.P("\t\treturn")
+	g.P("\tfi")
+	g.P("\t_sage_line")
+	g.P("\tfor ((i = 1; i < ${#_sage_args[@]}; i++)); do")
+	g.P("\t\tif [[ ${_sage_args[i]} != --* ]]; then")
+	g.P("\t\t\ttarget=${_sage_args[i]}")
+	g.P("\t\t\tbreak")
+	g.P("\t\tfi")
+	g.P("\tdone")
+	g.P("\tif [[ -z $target ]]; then")
+	g.P("\t\t_sage_reply \"$(\"_sage_sagefile_words_$_sage_id\" '')\"")
+	g.P("\telif [[ $_sage_cur != *=* ]]; then")
+	g.P("\t\t_sage_reply \"--help $(\"_sage_sagefile_words_$_sage_id\" \"$target\")\"")
+	g.P("\tfi")
+	g.P("}")
+	g.P()
+	g.P("complete -F _sage_sagefile sagefile")
+}
+
+func generateZshCompletion(
+	g *codegen.File, root string, makeDirs []completionMakeDir, sagefileTargets []completionTarget,
+) {
+	id := completionID(root)
+	g.P("# Completion of make targets and sagefile arguments for zsh.")
+	g.P("# Load with: source <(make -s sage-completion)")
+	g.P("#")
+	g.P("# The script can be loaded for multiple repositories, and completes the targets of the repository of the current")
+	g.P("# directory, or of the directory given to make with -C. Make in other directories is completed as before.")
+	g.P()
+	g.P("typeset -gA _sage_ids")
+	g.P("_sage_ids+=(", shellQuoted(root), " ", id, ")")
+	g.P()
+	g.P("# _sage_make_", id, " sets the targets and args of make in the directory with the provided prefix.")
+	g.P("_sage_make_", id, "() {")
+	g.P("\tlocal i")
+	g.P("\tcase $1 in")
+	for _, makeDir := range makeDirs {
+		g.P("\t", shellQuoted(makeDir.dir), ")")
+		g.P("\t\ttargets=(")
+		for _, target := range makeDir.targets {
+			g.P("\t\t\t", shellQuoted(zshDescribe(target.names[0], target.synopsis)))
+		}
+		g.P("\t\t)")
+		g.P("\t\t;;")
+	}
+	g.P("\tesac")
+	g.P("\tfor ((i = 2; i < CURRENT; i++)); do")
+	g.P("\t\tcase \"$1:${words[i]}\" in")
+	for _, makeDir := range makeDirs {
+		for _, target := range makeDir.targets {
+			if len(target.args) == 0 {
+				continue
+			}
+			g.P("\t\t", shellQuoted(makeDir.dir+":"+target.names[0]), ") args+=(", shellQuotedList(target.args), ") ;;")
+		}
+	}
+	g.P("\t\tesac")
+	g.P("\tdone")
+	g.P("}")
+	g.P()
+	g.P("# _sage_sagefile_", id, " sets the args of the provided sagefile target, or the targets when it's empty.")
+	g.P("_sage_sagefile_", id, "() {")
+	g.P("\tcase $1 in")
+	g.P("\t'')")
+	g.P("\t\ttargets=(")
+	for _, target := range sagefileTargets {
+		for _, name := range target.names {
+			g.P("\t\t\t", shellQuoted(zshDescribe(name, target.synopsis)))
+		}
+	}
+	g.P("\t\t)")
+	g.P("\t\t;;")
+	for _, target := range sagefileTargets {
+		if len(target.args) == 0 {
+			continue
+		}
+		quoted := make([]string, 0, len(target.names))
+		for _, name := range target.names {
+			quoted = append(quoted, shellQuoted(name))
+		}
+		g.P("\t", strings.Join(quoted, " | "), ") args=(", shellQuotedList(target.args), ") ;;")
+	}
+	g.P("\tesac")
+	g.P("}")
+	g.P()
+	g.P("_sage_make() {")
+	g.P("\tlocal dir=. root prefix i")
+	g.P("\tlocal -a targets args")
+	g.P("\tfor ((i = 2; i < CURRENT; i++)); do")
+	g.P("\t\tif [[ ${words[i]} == -C ]]; then")
+	g.P("\t\t\tdir=${words[i + 1]}")
+	g.P("\t\tfi")
+	g.P("\tdone")
+	g.P("\troot=$(git -C \"$dir\" rev-parse --show-toplevel 2>/dev/null)")
+	g.P("\tif [[ -z $root || -z ${_sage_ids[$root]} ]]; then")
+	g.P("\t\t_make \"$@\"")
+	g.P("\t\treturn")
+	g.P("\tfi")
+	g.P("\tprefix=$(git -C \"$dir\" rev-parse --show-prefix)")
+	g.P("\tprefix=${prefix%/}")
+	g.P("\t\"_sage_make_${_sage_ids[$root]}\" \"$prefix\"")
+	g.P("\t_describe -t targets 'make target' targets")
+	g.P("\tcompadd -S '' -- $args")
+	g.P("}")
+	g.P()
+	g.P("compdef _sage_make make")
+	g.P()
+	g.P("_sage_sagefile() {")
+	g.P("\tlocal root target i")
+	g.P("\tlocal -a targets args")
+	g.P("\troot=$(git rev-parse --show-toplevel 2>/dev/null)")
+	g.P("\tif [[ -z $root || -z ${_sage_ids[$root]} ]]; then")
+	g.P("\t\treturn")
+	g.P("\tfi")
+	g.P("\tfor ((i = 2; i < CURRENT; i++)); do")
+	g.P("\t\tif [[ ${words[i]} != --* ]]; then")
+	g.P("\t\t\ttarget=${words[i]}")
+	g.P("\t\t\tbreak")
+	g.P("\t\tfi")
+	g.P("\tdone")
+	g.P("\t\"_sage_sagefile_${_sage_ids[$root]}\" \"$target\"")
+	g.P("\tif [[ -z $target ]]; then")
+	g.P("\t\t_describe -t targets 'sagefile target' targets")
+	g.P("\t\tcompadd -- ", strings.Join(sagefileOptions(), " "))
+	g.P("\t\treturn")
+	g.P("\tfi")
+	g.P("\tcompadd -S '' -- $args")
+	g.P("\tcompadd -- --help")
+	g.P("}")
+	g.P()
+	g.P("compdef _sage_sagefile sagefile")
+}
+
+func generateFishCompletion(
+	g *codegen.File, root string, makeDirs []completionMakeDir, sagefileTargets []completionTarget,
+) {
+	g.P("# Completion of make targets and sagefile arguments for fish.")
+	g.P("# Load with: make -s sage-completion | source")
+	g.P()
+	g.P("function __sage_make_dir")
+	g.P("\tset -l dir .")
+	g.P("\tset -l tokens (commandline -opc)")
+	g.P("\tfor i in (seq 2 (count $tokens))")
+	g.P("\t\tif test \"$tokens[$i]\" = -C; and test $i -lt (count $tokens)")
+	g.P("\t\t\tset dir $tokens[(math $i + 1)]")
+	g.P("\t\tend")
+	g.P("\tend")
+	g.P("\tset -l root (git -C $dir rev-parse --show-toplevel 2>/dev/null)")
+	g.P("\ttest \"$root\" = ", shellQuoted(root), "; or return 1")
+	g.P("\tset -l prefix (git -C $dir rev-parse --show-prefix)")
+	g.P("\ttest \"$prefix\" = \"$argv[1]/\"; or test \"$prefix\" = \"$argv[1]\"")
+	g.P("end")
+	g.P()
+	for _, makeDir := range makeDirs {
+		condition := "__sage_make_dir"
+		if makeDir.dir != "" {
+			condition += " " + makeDir.dir
+		}
+		for _, target := range makeDir.targets {
+			g.P(
+				"complete -c make -f -n ", shellQuoted(condition), " -a ", shellQuoted(target.names[0]),
+				" -d ", shellQuoted(target.synopsis),
+			)
+			if len(target.args) == 0 {
+				continue
+			}
+			g.P(
+				"complete -c make -f -n ",
+				shellQuoted(condition+"; and __fish_seen_subcommand_from "+target.names[0]),
+				" -a ", shellQuoted(strings.Join(target.args, " ")),
+			)
+		}
+	}
+	g.P()
+	g.P("complete -c sagefile -f -l help -d 'Print usage'")
+	g.P("complete -c sagefile -f -n __fish_use_subcommand -l dry-run -d 'Print the dependency graph of the target'")
+	g.P("complete -c sagefile -f -n __fish_use_subcommand -l watch -d 'Run the target again whenever files change'")
+	g.P("complete -c sagefile -f -n __fish_use_subcommand -l output -xa 'stream grouped' -d 'Output mode'")
+	for _, target := range sagefileTargets {
+		for _, name := range target.names {
+			g.P(
+				"complete -c sagefile -f -n __fish_use_subcommand -a ", shellQuoted(name),
+				" -d ", shellQuoted(target.synopsis),
+			)
+		}
+		for _, arg := range target.args {
+			g.P(
+				"complete -c sagefile -f -n ",
+				shellQuoted("__fish_seen_subcommand_from "+strings.Join(target.names, " ")),
+				" -l ", shellQuoted(strings.TrimSuffix(strings.TrimPrefix(arg, "--"), "=")), " -r",
+			)
+		}
+	}
+}
+
+// completionID returns an identifier of the repository with the provided root, for the names of the shell functions
+// completing its targets, which lets the completion scripts of multiple repositories be loaded at the same time.
+func completionID(root string) string {
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(root)))[:12]
+}
+
+// sagefileOptions returns the options of the sagefile binary, to complete before the target.
+func sagefileOptions() []string {
+	return []string{
+		"--help", "--dry-run", "--dry-run=dot", "--dry-run=mermaid", "--watch",
+		"--output=" + string(OutputStream), "--output=" + string(OutputGrouped),
+	}
+}
+
+// zshDescribe returns a _describe entry for the name and description.
+func zshDescribe(name, description string) string {
+	name = strings.ReplaceAll(name, ":", `\:`)
+	if description == "" {
+		return name
+	}
+	return name + ":" + description
+}
+
+// shellQuotedList quotes each value for a shell and joins them with spaces.
+func shellQuotedList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, shellQuoted(value))
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuoted quotes a string for a shell script.
+func shellQuoted(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
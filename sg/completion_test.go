@@ -0,0 +1,160 @@
+package sg
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+// updateGolden updates the golden files of tests with their actual output, with go test -run <test> -update.
+//
+//nolint:gochecknoglobals
+var updateGolden = flag.Bool("update", false, "update golden files")
+
+type CompletionOps Namespace
+
+func TestCompletionTargets(t *testing.T) {
+	const src = `package main
+
+import "context"
+
+type CompletionOps sg.Namespace
+
+// Deploy deploys the service.
+func Deploy(ctx context.Context, env string, services ...string) error { return nil }
+
+// Restart restarts a service.
+func (CompletionOps) Restart(ctx context.Context, service string) error { return nil }
+`
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := doc.NewFromFiles(fset, []*ast.File{file}, "./")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mks := []Makefile{
+		{Path: FromGitRoot("Makefile"), Completion: true},
+		{Path: FromGitRoot("ops", "Makefile"), Namespace: CompletionOps{}},
+	}
+	makeDirs, err := completionMakeDirs(pkg, mks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectedMakeTargets := map[string][]string{
+		"": {
+			"help", "sage", "update-sage", "clean-sage", "sage-completion", "deploy", "watch-deploy", "completion-ops",
+		},
+		"ops": {"help", "sage", "update-sage", "clean-sage", "restart", "watch-restart"},
+	}
+	if len(makeDirs) != len(expectedMakeTargets) {
+		t.Fatalf("expected %d directories but got %d", len(expectedMakeTargets), len(makeDirs))
+	}
+	for _, makeDir := range makeDirs {
+		var names []string
+		for _, target := range makeDir.targets {
+			names = append(names, target.names...)
+			isDeploy := target.names[0] == "deploy" || target.names[0] == "watch-deploy"
+			if isDeploy && !reflect.DeepEqual(target.args, []string{"env=", "services="}) {
+				t.Errorf("unexpected deploy variables %v", target.args)
+			}
+		}
+		if !reflect.DeepEqual(names, expectedMakeTargets[makeDir.dir]) {
+			t.Errorf("expected targets %v in %q but got %v", expectedMakeTargets[makeDir.dir], makeDir.dir, names)
+		}
+	}
+	sagefileTargets := completionSagefileTargets(pkg, mks)
+	expectedSagefileTargets := []completionTarget{
+		{
+			names:    []string{"Deploy", "deploy"},
+			synopsis: "Deploy deploys the service.",
+			args:     []string{"--env=", "--services="},
+		},
+		{
+			names:    []string{"CompletionOps:Restart", "completion-ops:restart"},
+			synopsis: "Restart restarts a service.",
+			args:     []string{"--service="},
+		},
+	}
+	if !reflect.DeepEqual(sagefileTargets, expectedSagefileTargets) {
+		t.Errorf("expected sagefile targets %v but got %v", expectedSagefileTargets, sagefileTargets)
+	}
+}
+
+func TestGenerateCompletions(t *testing.T) {
+	pkg := parseTestPackage(t, `package main
+
+import "context"
+
+type CompletionOps sg.Namespace
+
+type env string
+
+const (
+	envDev  env = "dev"
+	envProd env = "prod"
+)
+
+// Deploy deploys the service, with the user's "quoted" settings.
+func Deploy(ctx context.Context, e env, services ...string) error { return nil }
+
+// Restart restarts a service.
+func (CompletionOps) Restart(ctx context.Context, service string) error { return nil }
+`)
+	mks := []Makefile{
+		{Path: FromGitRoot("Makefile"), Completion: true},
+		{Path: FromGitRoot("ops", "Makefile"), Namespace: CompletionOps{}},
+	}
+	makeDirs, err := completionMakeDirs(pkg, mks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sagefileTargets := completionSagefileTargets(pkg, mks)
+	for _, tt := range []struct {
+		shell    string
+		filename string
+		generate func(*codegen.File, string, []completionMakeDir, []completionTarget)
+	}{
+		{shell: "bash", filename: "sage.bash", generate: generateBashCompletion},
+		{shell: "zsh", filename: "sage.zsh", generate: generateZshCompletion},
+		{shell: "fish", filename: "sage.fish", generate: generateFishCompletion},
+	} {
+		tt := tt
+		t.Run(tt.shell, func(t *testing.T) {
+			g := codegen.NewMakefile(codegen.FileConfig{GeneratedBy: "go.einride.tech/sage"})
+			tt.generate(g, "/home/user/repo", makeDirs, sagefileTargets)
+			golden := filepath.Join("testdata", "completion", tt.filename)
+			if *updateGolden {
+				if err := os.WriteFile(golden, g.RawContent(), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(g.RawContent(), expected) {
+				t.Errorf("expected the content of %s but got\n%s", golden, g.RawContent())
+			}
+			// Check the syntax of the script, when the shell is installed.
+			if _, err := exec.LookPath(tt.shell); err != nil {
+				t.Skipf("%s not installed", tt.shell)
+			}
+			if output, err := exec.Command(tt.shell, "-n", golden).CombinedOutput(); err != nil {
+				t.Errorf("%s -n %s: %v: %s", tt.shell, golden, err, output)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package sg
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
@@ -17,9 +18,53 @@ import (
 // Dependencies must be of type func(context.Context) error or Target.
 //
 // Each function will be run exactly once, even across multiple calls to Deps.
+//
+// If any of the dependencies fail, their errors are logged and the process exits. Use DepsE to handle the errors.
+// In watch mode, Deps returns instead, and the run of the watched target is failed and canceled. Later calls to Deps
+// in the failed run return without running anything.
 func Deps(ctx context.Context, functions ...interface{}) {
+	run := getWatchRun(ctx)
+	if run != nil && run.isFailed() {
+		return
+	}
+	if err := DepsE(ctx, functions...); err != nil {
+		var depsErr *DepsError
+		if !errors.As(err, &depsErr) {
+			panic(err)
+		}
+		if run != nil && !run.fail() {
+			// Dependencies canceled by an earlier failure of the run are not reported again.
+			return
+		}
+		for _, targetErr := range depsErr.Errors {
+			NewLogger(targetErr.Name).Println(targetErr.Err)
+		}
+		if run == nil {
+			Exit(1)
+		}
+	}
+}
+
+// DepsE works like Deps, except that it returns a *DepsError describing each failed dependency instead of exiting
+// the process.
+//
+// If ctx was created with ContextWithFailFast, the context of all dependencies is canceled as soon as the first
+// dependency fails.
+func DepsE(ctx context.Context, functions ...interface{}) error {
 	errs := make([]error, len(functions))
 	checkedFunctions := checkFunctions(functions...)
+	if graph := getDependencyGraph(ctx); graph != nil {
+		graph.walk(ctx, checkedFunctions)
+		return nil
+	}
+	if isFailFast(ctx) {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		ctx = context.WithValue(ctx, failFastCancelContextKey{}, cancel)
+	}
+	// Release the parallelism held by the calling target while waiting for its dependencies.
+	defer releaseSlot(ctx)()
 	var wg sync.WaitGroup
 	for i, f := range checkedFunctions {
 		i, f := i, f
@@ -36,35 +81,65 @@ func Deps(ctx context.Context, functions ...interface{}) {
 			}
 		}
 		ctx := withDependency(ctx, f)
+		// ran reports whether this call ran the target, or if it had already run.
+		var ran bool
+		run := func(ctx context.Context) error {
+			ran = true
+			return runScheduled(ctx, f, func(ctx context.Context) (err error) {
+				ctx, flush := groupOutput(ctx, loggerName(f.Name()))
+				defer func() {
+					if v := recover(); v != nil {
+						flush(fmt.Errorf("%s", v))
+						panic(v)
+					}
+					flush(err)
+				}()
+				return reportTarget(ctx, f, func(ctx context.Context) error {
+					return traceTarget(ctx, f)
+				})
+			})
+		}
 
 		// Forcing serial deps can protect low-powered build machines from running out of memory.
-		// EXPERIMENTAL: Support for this environment variable may be removed at any time.
+		// EXPERIMENTAL: Support for this environment variable may be removed at any time, use SAGE_MAX_PARALLEL.
 		if forceSerialDeps, ok := os.LookupEnv("SAGE_FORCE_SERIAL_DEPS"); ok && isTrue(forceSerialDeps) {
-			errs[i] = runner.RunOnce(WithLogger(ctx, NewLogger(f.Name())), f.ID(), f.Run)
+			errs[i] = runner.RunOnce(WithLogger(ctx, NewLogger(f.Name())), f.ID(), run)
+			if !ran {
+				reportSkipped(ctx, f)
+			}
+			cancelOnFailure(ctx, errs[i])
 			continue
 		}
 		wg.Add(1)
 		go func() {
 			defer func() {
 				if v := recover(); v != nil {
-					errs[i] = fmt.Errorf("%s", v)
+					errs[i] = recoveredError(v)
 				}
+				cancelOnFailure(ctx, errs[i])
 				wg.Done()
 			}()
-			errs[i] = runner.RunOnce(WithLogger(ctx, NewLogger(f.Name())), f.ID(), f.Run)
+			errs[i] = runner.RunOnce(WithLogger(ctx, NewLogger(f.Name())), f.ID(), run)
+			if !ran {
+				reportSkipped(ctx, f)
+			}
 		}()
 	}
 	wg.Wait()
-	var exitError bool
+	var depsErr DepsError
 	for i, err := range errs {
 		if err != nil {
-			NewLogger(checkedFunctions[i].Name()).Println(err)
-			exitError = true
+			depsErr.Errors = append(depsErr.Errors, &TargetError{
+				Name: checkedFunctions[i].Name(),
+				ID:   checkedFunctions[i].ID(),
+				Err:  err,
+			})
 		}
 	}
-	if exitError {
-		os.Exit(1)
+	if len(depsErr.Errors) > 0 {
+		return &depsErr
 	}
+	return nil
 }
 
 // SerialDeps works like Deps except running all dependencies serially instead of in parallel.
@@ -74,6 +149,14 @@ func SerialDeps(ctx context.Context, targets ...interface{}) {
 	}
 }
 
+// recoveredError returns the error of a recovered panic.
+func recoveredError(v interface{}) error {
+	if err, ok := v.(error); ok {
+		return err
+	}
+	return fmt.Errorf("%s", v)
+}
+
 func checkFunctions(functions ...interface{}) []Target {
 	result := make([]Target, 0, len(functions))
 	for _, f := range functions {
@@ -110,3 +193,71 @@ func withDependency(ctx context.Context, target Target) context.Context {
 	dependencies = append(dependencies, target)
 	return context.WithValue(ctx, dependencyChainContextKey{}, dependencies)
 }
+
+type failFastContextKey struct{}
+
+type failFastCancelContextKey struct{}
+
+// ContextWithFailFast returns a context that makes DepsE, Deps and SerialDeps cancel the context of all running
+// dependencies as soon as one of them fails.
+func ContextWithFailFast(ctx context.Context) context.Context {
+	return context.WithValue(ctx, failFastContextKey{}, true)
+}
+
+func isFailFast(ctx context.Context) bool {
+	failFast, _ := ctx.Value(failFastContextKey{}).(bool)
+	return failFast
+}
+
+func cancelOnFailure(ctx context.Context, err error) {
+	if err == nil {
+		return
+	}
+	if cancel, ok := ctx.Value(failFastCancelContextKey{}).(context.CancelFunc); ok {
+		cancel()
+	}
+}
+
+// DepsError is returned by DepsE when one or more dependencies fail.
+type DepsError struct {
+	// Errors of the failed dependencies, in the order they were provided to DepsE.
+	Errors []*TargetError
+}
+
+// Error implements error.
+func (e *DepsError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Unwrap returns the errors of the failed dependencies.
+func (e *DepsError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// TargetError is the error of a single failed Target.
+type TargetError struct {
+	// Name of the failed Target.
+	Name string
+	// ID of the failed Target.
+	ID string
+	// Err returned by the failed Target.
+	Err error
+}
+
+// Error implements error.
+func (e *TargetError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the error returned by the Target.
+func (e *TargetError) Unwrap() error {
+	return e.Err
+}
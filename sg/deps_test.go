@@ -0,0 +1,79 @@
+package sg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// Some tests need dependencies to run in parallel, also on machines with a single CPU.
+	if err := os.Setenv("SAGE_MAX_PARALLEL", "4"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestDepsE(t *testing.T) {
+	t.Run("no errors", func(t *testing.T) {
+		if err := DepsE(context.Background(), depsSucceed); err != nil {
+			t.Fatal(err)
+		}
+	})
+	t.Run("errors", func(t *testing.T) {
+		err := DepsE(context.Background(), depsSucceed, depsFail, depsFailToo)
+		var depsErr *DepsError
+		if !errors.As(err, &depsErr) {
+			t.Fatalf("expected *DepsError but got %v", err)
+		}
+		if len(depsErr.Errors) != 2 {
+			t.Fatalf("expected 2 errors but got %d", len(depsErr.Errors))
+		}
+		if expected := "go.einride.tech/sage/sg.depsFail"; depsErr.Errors[0].Name != expected {
+			t.Errorf("expected %q but got %q", expected, depsErr.Errors[0].Name)
+		}
+		if !errors.Is(depsErr.Errors[0], errDepsFail) {
+			t.Errorf("expected %v to wrap %v", depsErr.Errors[0], errDepsFail)
+		}
+	})
+	t.Run("fail fast", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		err := DepsE(ContextWithFailFast(ctx), depsWaitForCancel, depsFailFast)
+		var depsErr *DepsError
+		if !errors.As(err, &depsErr) {
+			t.Fatalf("expected *DepsError but got %v", err)
+		}
+		if len(depsErr.Errors) != 2 {
+			t.Fatalf("expected 2 errors but got %d", len(depsErr.Errors))
+		}
+		if !errors.Is(depsErr.Errors[0], context.Canceled) {
+			t.Errorf("expected %v to be canceled", depsErr.Errors[0])
+		}
+	})
+}
+
+var errDepsFail = errors.New("fail")
+
+func depsSucceed(_ context.Context) error {
+	return nil
+}
+
+func depsFail(_ context.Context) error {
+	return errDepsFail
+}
+
+func depsFailToo(_ context.Context) error {
+	return errDepsFail
+}
+
+func depsFailFast(_ context.Context) error {
+	return errDepsFail
+}
+
+func depsWaitForCancel(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
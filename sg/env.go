@@ -0,0 +1,195 @@
+package sg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+type envContextKey struct{}
+
+// envEntry sets or unsets an environment variable of Command.
+type envEntry struct {
+	key   string
+	value string
+	unset bool
+}
+
+// ContextWithEnv returns a context with environment variables in the format KEY=value, which are set by Command.
+//
+// The environment of Command is the environment of the process, with the environment variables of each call to
+// ContextWithEnv, ContextWithoutEnv and ContextWithEnvFile applied in order. A variable set or unset by a later call
+// takes precedence over an earlier one.
+func ContextWithEnv(ctx context.Context, env ...string) context.Context {
+	entries := make([]envEntry, 0, len(env))
+	for _, kv := range env {
+		key, value, _ := strings.Cut(kv, "=")
+		entries = append(entries, envEntry{key: key, value: value})
+	}
+	return withEnvEntries(ctx, entries)
+}
+
+// ContextWithoutEnv returns a context with environment variables which are unset by Command, including variables
+// of the process environment and variables set by earlier calls to ContextWithEnv.
+func ContextWithoutEnv(ctx context.Context, keys ...string) context.Context {
+	entries := make([]envEntry, 0, len(keys))
+	for _, key := range keys {
+		entries = append(entries, envEntry{key: key, unset: true})
+	}
+	return withEnvEntries(ctx, entries)
+}
+
+// ContextWithEnvFile returns a context with the environment variables of the dotenv file at path, which are set by
+// Command.
+//
+// Each line of the file is a KEY=value pair, optionally prefixed by export. Lines starting with # are comments.
+// Values can be single-quoted, which keeps them as is, or double-quoted, which allows escape sequences and newlines.
+// References to ${VAR} and $VAR in unquoted and double-quoted values are expanded with the variables defined earlier
+// in the file, or else with the environment of Command for ctx.
+func ContextWithEnvFile(ctx context.Context, path string) (context.Context, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read env file: %w", err)
+	}
+	env, err := parseEnvFile(string(data), commandEnv(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("parse env file %s: %w", path, err)
+	}
+	return ContextWithEnv(ctx, env...), nil
+}
+
+func withEnvEntries(ctx context.Context, entries []envEntry) context.Context {
+	parent, _ := ctx.Value(envContextKey{}).([]envEntry)
+	result := make([]envEntry, 0, len(parent)+len(entries))
+	result = append(result, parent...)
+	result = append(result, entries...)
+	return context.WithValue(ctx, envContextKey{}, result)
+}
+
+// commandEnv returns the environment of Command for ctx, in the format KEY=value.
+func commandEnv(ctx context.Context) []string {
+	result := os.Environ()
+	entries, _ := ctx.Value(envContextKey{}).([]envEntry)
+	for _, entry := range entries {
+		result = removeEnv(result, entry.key)
+		if !entry.unset {
+			result = append(result, entry.key+"="+entry.value)
+		}
+	}
+	return result
+}
+
+func removeEnv(environ []string, key string) []string {
+	result := environ[:0]
+	for _, kv := range environ {
+		if !strings.HasPrefix(kv, key+"=") {
+			result = append(result, kv)
+		}
+	}
+	return result
+}
+
+func lookupEnv(environ []string, key string) (string, bool) {
+	for i := len(environ) - 1; i >= 0; i-- {
+		if value, ok := strings.CutPrefix(environ[i], key+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// parseEnvFile parses the contents of a dotenv file into variables in the format KEY=value, expanding references to
+// variables defined earlier in the file or in environ.
+func parseEnvFile(data string, environ []string) ([]string, error) {
+	var result []string
+	expand := func(s string) string {
+		return os.Expand(s, func(key string) string {
+			if value, ok := lookupEnv(result, key); ok {
+				return value
+			}
+			value, _ := lookupEnv(environ, key)
+			return value
+		})
+	}
+	scanner := bufio.NewScanner(strings.NewReader(data))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !isEnvKey(key) {
+			return nil, fmt.Errorf("line %d: invalid variable definition %q", lineNumber, line)
+		}
+		value = strings.TrimLeftFunc(value, unicode.IsSpace)
+		switch {
+		case strings.HasPrefix(value, "'"):
+			end := strings.Index(value[1:], "'")
+			if end == -1 {
+				return nil, fmt.Errorf("line %d: unterminated single-quoted value", lineNumber)
+			}
+			value = value[1 : end+1]
+		case strings.HasPrefix(value, `"`):
+			// Double-quoted values may span multiple lines.
+			quoted := value[1:]
+			for {
+				if end, ok := findClosingQuote(quoted); ok {
+					quoted = quoted[:end]
+					break
+				}
+				if !scanner.Scan() {
+					return nil, fmt.Errorf("line %d: unterminated double-quoted value", lineNumber)
+				}
+				lineNumber++
+				quoted += "\n" + scanner.Text()
+			}
+			value = expand(unescapeEnvValue(quoted))
+		default:
+			if i := strings.Index(value, " #"); i != -1 {
+				value = value[:i]
+			}
+			value = expand(strings.TrimSpace(value))
+		}
+		result = append(removeEnv(result, key), key+"="+value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// findClosingQuote returns the index of the first unescaped double quote in s.
+func findClosingQuote(s string) (int, bool) {
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func unescapeEnvValue(s string) string {
+	return strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`).Replace(s)
+}
+
+func isEnvKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for i, r := range key {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package sg
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCommandEnv(t *testing.T) {
+	t.Setenv("SAGE_TEST_INHERITED", "inherited")
+	t.Setenv("SAGE_TEST_OVERRIDDEN", "inherited")
+	ctx := ContextWithEnv(context.Background(), "SAGE_TEST_OVERRIDDEN=first", "SAGE_TEST_FIRST=first")
+	ctx = ContextWithEnv(ctx, "SAGE_TEST_OVERRIDDEN=second")
+	ctx = ContextWithoutEnv(ctx, "SAGE_TEST_INHERITED", "SAGE_TEST_FIRST")
+	envFile := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(envFile, []byte("SAGE_TEST_FILE=${SAGE_TEST_OVERRIDDEN}-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, err := ContextWithEnvFile(ctx, envFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := commandEnv(ctx)
+	for key, expected := range map[string]string{
+		"SAGE_TEST_OVERRIDDEN": "second",
+		"SAGE_TEST_FILE":       "second-file",
+	} {
+		if actual, ok := lookupEnv(env, key); !ok || actual != expected {
+			t.Errorf("expected %s=%s but got %q", key, expected, actual)
+		}
+	}
+	for _, key := range []string{"SAGE_TEST_INHERITED", "SAGE_TEST_FIRST"} {
+		if _, ok := lookupEnv(env, key); ok {
+			t.Errorf("expected %s to be unset", key)
+		}
+	}
+	if actual := len(env) - len(removeEnv(append([]string(nil), env...), "SAGE_TEST_OVERRIDDEN")); actual != 1 {
+		t.Errorf("expected SAGE_TEST_OVERRIDDEN to be set once, but was set %d times", actual)
+	}
+}
+
+func Test_parseEnvFile(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		data     string
+		expected []string
+	}{
+		{
+			name:     "unquoted",
+			data:     "# comment\nFOO=bar\n\nexport BAZ = qux # comment\n",
+			expected: []string{"FOO=bar", "BAZ=qux"},
+		},
+		{
+			name:     "single-quoted",
+			data:     `FOO='${HOME} # not a comment'`,
+			expected: []string{"FOO=${HOME} # not a comment"},
+		},
+		{
+			name:     "double-quoted",
+			data:     "FOO=\"a \\\"quoted\\\"\\nvalue\"\nBAR=\"multi\nline\"",
+			expected: []string{"FOO=a \"quoted\"\nvalue", "BAR=multi\nline"},
+		},
+		{
+			name:     "expansion",
+			data:     "FOO=foo\nBAR=\"${FOO}-$ENVIRON-${MISSING}\"\nFOO=${FOO}${FOO}",
+			expected: []string{"BAR=foo-environ-", "FOO=foofoo"},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := parseEnvFile(tt.data, []string{"ENVIRON=environ"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tt.expected, actual) {
+				t.Errorf("expected %q but got %q", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func Test_parseEnvFile_invalid(t *testing.T) {
+	for _, data := range []string{"FOO", "1FOO=bar", "FOO='bar", `FOO="bar`} {
+		if _, err := parseEnvFile(data, nil); err == nil {
+			t.Errorf("expected error parsing %q", data)
+		}
+	}
+}
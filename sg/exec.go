@@ -1,7 +1,6 @@
 package sg
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
@@ -10,72 +9,183 @@ import (
 	"os"
 	"os/exec"
 	"strings"
+	"sync"
+	"time"
 )
 
-type cmdEnvCtxKey string
-
-//nolint:gochecknoglobals
-var cmdEnvKey cmdEnvCtxKey = "cmdEnv"
-
-// ContextWithEnv returns a context with environment variables which are appended to Command.
-func ContextWithEnv(ctx context.Context, env ...string) context.Context {
-	return context.WithValue(ctx, cmdEnvKey, env)
-}
+// interruptGracePeriod is the time a command has to exit after being interrupted, before it's killed.
+const interruptGracePeriod = 10 * time.Second
 
 // Command should be used when returning exec.Cmd from tools to set opinionated standard fields.
+//
+// The command is started in its own process group, which is made the foreground process group of the terminal when
+// stdin is a terminal. When ctx is done, the whole process group is interrupted, and killed if it hasn't exited within
+// a grace period.
 func Command(ctx context.Context, path string, args ...string) *exec.Cmd {
 	cmd := exec.CommandContext(ctx, path)
 	cmd.Args = append(cmd.Args, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return interruptProcessGroup(cmd.Process, interruptGracePeriod)
+	}
+	cmd.WaitDelay = interruptGracePeriod
 	cmd.Dir = FromGitRoot(".")
-	cmd.Env = os.Environ()
-	if v := ctx.Value(cmdEnvKey); v != nil {
-		env, ok := v.([]string)
-		if ok {
-			cmd.Env = append(cmd.Env, env...)
+	cmd.Env = commandEnv(ctx)
+	cmd.Env = prependPath(cmd.Env, FromBinDir())
+	cmd.Stderr = newLogWriter(ctx, cmd, reportWriter(ctx, outputWriter(ctx, os.Stderr)))
+	cmd.Stdout = newLogWriter(ctx, cmd, outputWriter(ctx, os.Stdout))
+	if getDependencyGraph(ctx) != nil {
+		// Keep the arguments for logging, but run a command that does nothing, or fail without running anything.
+		Logger(ctx).Printf("dry run: %s", strings.Join(cmd.Args, " "))
+		if noop, err := exec.LookPath("true"); err == nil {
+			cmd.Path = noop
+		} else {
+			cmd.Err = fmt.Errorf("dry run: no command that does nothing: %w", err)
 		}
 	}
-	cmd.Env = prependPath(cmd.Env, FromBinDir())
-	cmd.Stderr = newLogWriter(ctx, os.Stderr)
-	cmd.Stdout = newLogWriter(ctx, os.Stdout)
+	if getTracer(ctx) != nil {
+		// Process spans are recorded through stderr, which is replaced less often than stdout, e.g. by Output.
+		cmd.Stderr = &traceWriter{Writer: cmd.Stderr, ctx: ctx, args: cmd.Args}
+	}
 	return cmd
 }
 
-func newLogWriter(ctx context.Context, out io.Writer) *logWriter {
+func newLogWriter(ctx context.Context, cmd *exec.Cmd, out io.Writer) *logWriter {
 	logger := log.New(out, Logger(ctx).Prefix(), 0)
-	return &logWriter{logger: logger, out: out}
+	return &logWriter{
+		logger:          logger,
+		out:             out,
+		cmd:             cmd,
+		annotationRules: getAnnotationRules(ctx),
+		secrets:         getSecrets(ctx),
+	}
 }
 
+// maxLogLineSize is the size at which an incomplete line of output is written without waiting for the line to end.
+const maxLogLineSize = 64 * 1024
+
+// partialLineDelay is the time an incomplete line of output that ends with the start of a secret is held back, before
+// it's written without waiting for the rest of the secret.
+const partialLineDelay = 100 * time.Millisecond
+
+// logWriter writes the output of a command line by line, with the logger prefix.
 type logWriter struct {
 	logger            *log.Logger
 	out               io.Writer
 	hasFileReferences bool
+	// cmd is the command writing to the logWriter, whose directory file references are relative to.
+	cmd             *exec.Cmd
+	annotationRules []AnnotationRule
+	secrets         []string
+	// mu guards partial and timer, which are accessed by Write and by the timer.
+	mu sync.Mutex
+	// partial is the incomplete last line of output. It's written at once, e.g. for prompts and progress bars, unless
+	// it ends with the start of a secret, so that secrets are redacted even when they are split across writes.
+	partial []byte
+	// timer writes partial when no more output follows within partialLineDelay.
+	timer *time.Timer
 }
 
+// Write implements io.Writer.
 func (l *logWriter) Write(p []byte) (n int, err error) {
-	in := bufio.NewScanner(bytes.NewReader(p))
-	for in.Scan() {
-		line := in.Text()
-		if !l.hasFileReferences {
-			l.hasFileReferences = hasFileReferences(line)
-			if l.hasFileReferences {
-				// If line has file reference (e.g. lint errors), print empty line with logger prefix.
-				// This enables GitHub to autodetect the file references and print them in the PR review.
-				l.logger.Println()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stopTimer()
+	l.partial = append(l.partial, p...)
+	for {
+		i := bytes.IndexByte(l.partial, '\n')
+		if i == -1 {
+			break
+		}
+		l.writeLine(string(bytes.TrimSuffix(l.partial[:i], []byte("\r"))))
+		l.partial = l.partial[i+1:]
+	}
+	switch {
+	case len(l.partial) == 0:
+	case len(l.partial) >= maxLogLineSize || !endsWithSecretPrefix(l.secrets, l.partial):
+		l.flush()
+	default:
+		var timer *time.Timer
+		timer = time.AfterFunc(partialLineDelay, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			// The timer may have been replaced by a write while it was waiting for the lock.
+			if l.timer == timer {
+				l.timer = nil
+				l.flush()
+			}
+		})
+		l.timer = timer
+	}
+	return len(p), nil
+}
+
+// ReadFrom implements io.ReaderFrom, and writes the last line of output when it doesn't end with a newline.
+//
+// The standard library copies the output of a process to a non-file writer using ReadFrom when available.
+func (l *logWriter) ReadFrom(r io.Reader) (int64, error) {
+	n, err := io.Copy(struct{ io.Writer }{l}, r)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.stopTimer()
+	l.flush()
+	return n, err
+}
+
+func (l *logWriter) stopTimer() {
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+}
+
+// flush writes the incomplete last line of output.
+func (l *logWriter) flush() {
+	if len(l.partial) > 0 {
+		l.writeLine(string(bytes.TrimSuffix(l.partial, []byte("\r"))))
+		l.partial = nil
+	}
+}
+
+// endsWithSecretPrefix reports whether p ends with the start of a secret, which may continue in the next write.
+func endsWithSecretPrefix(secrets []string, p []byte) bool {
+	for _, secret := range secrets {
+		for i := 1; i < len(secret) && i <= len(p); i++ {
+			if bytes.HasSuffix(p, []byte(secret[:i])) {
+				return true
 			}
 		}
-		if l.hasFileReferences {
-			// Prints line without logger prefix.
-			// Trim space to ensure that file references start at the beginning of the line.
-			line = strings.TrimSpace(line)
-			_, _ = fmt.Fprintln(l.out, line)
-		} else {
+	}
+	return false
+}
+
+func (l *logWriter) writeLine(line string) {
+	line = redactSecrets(l.secrets, line)
+	if isGitHubActions() {
+		// Report problems of tools with annotation rules as annotations, which GitHub shows in the log and in the PR
+		// review.
+		if annotation, ok := parseAnnotation(l.annotationRules, l.cmd.Dir, line); ok {
 			l.logger.Print(line)
+			_, _ = fmt.Fprintln(l.out, annotation.workflowCommand())
+			return
 		}
 	}
-	if err := in.Err(); err != nil {
-		l.logger.Fatal(err)
+	if !l.hasFileReferences {
+		l.hasFileReferences = hasFileReferences(line)
+		if l.hasFileReferences {
+			// If line has file reference (e.g. lint errors), print empty line with logger prefix.
+			// This makes file references start at the beginning of the line, e.g. for terminals to link them.
+			l.logger.Println()
+		}
+	}
+	if l.hasFileReferences {
+		// Prints line without logger prefix.
+		// Trim space to ensure that file references start at the beginning of the line.
+		line = strings.TrimSpace(line)
+		_, _ = fmt.Fprintln(l.out, line)
+	} else {
+		l.logger.Print(line)
 	}
-	return len(p), nil
 }
 
 func hasFileReferences(line string) bool {
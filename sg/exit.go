@@ -0,0 +1,38 @@
+package sg
+
+import (
+	"os"
+	"sync"
+)
+
+// global state for exit handlers.
+//
+//nolint:gochecknoglobals
+var (
+	exitMu       sync.Mutex
+	exitOnce     sync.Once
+	exitHandlers []func()
+)
+
+// AtExit registers a function to be called by Exit before the process exits.
+func AtExit(fn func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHandlers = append(exitHandlers, fn)
+}
+
+// Exit calls all functions registered with AtExit, in reverse order of registration, and exits the process with the
+// provided status code.
+//
+// The registered functions are called at most once, even when Exit is called concurrently.
+func Exit(code int) {
+	exitOnce.Do(func() {
+		exitMu.Lock()
+		handlers := exitHandlers
+		exitMu.Unlock()
+		for i := len(handlers) - 1; i >= 0; i-- {
+			handlers[i]()
+		}
+	})
+	os.Exit(code)
+}
@@ -7,6 +7,7 @@ import (
 	"reflect"
 	"runtime"
 	"strings"
+	"time"
 )
 
 // Target represents a target function that can be run with Deps.
@@ -60,9 +61,13 @@ func newFn(f interface{}, args ...interface{}) (Target, error) {
 	}
 	for _, arg := range args {
 		argT := v.Type().In(x)
-		switch argT {
-		case reflect.TypeOf(0), reflect.TypeOf(""), reflect.TypeOf(false):
+		switch {
+		case argT.Kind() == reflect.String:
+			// ok, including named string types
+		case argT == reflect.TypeOf(0), argT == reflect.TypeOf(false), argT == reflect.TypeOf(0.0):
 			// ok
+		case argT == reflect.TypeOf(time.Duration(0)), argT == reflect.TypeOf([]string(nil)):
+			// ok, variadic string arguments are passed as a []string
 		default:
 			return nil, fmt.Errorf("argument %d (%s), is not a supported argument type", x, argT)
 		}
@@ -98,7 +103,11 @@ func newFn(f interface{}, args ...interface{}) (Target, error) {
 			for _, arg := range args {
 				callArgs = append(callArgs, reflect.ValueOf(arg))
 			}
-			ret := v.Call(callArgs)
+			call := v.Call
+			if v.Type().IsVariadic() {
+				call = v.CallSlice
+			}
+			ret := call(callArgs)
 			if ret[0].IsNil() {
 				return nil
 			}
@@ -2,7 +2,9 @@ package sg
 
 import (
 	"context"
+	"reflect"
 	"testing"
+	"time"
 )
 
 func TestFn_Name(t *testing.T) {
@@ -53,3 +55,20 @@ type namespace Namespace
 func (namespace) MyFunc(_ context.Context) error {
 	return nil
 }
+
+type myEnum string
+
+func TestFn_Args(t *testing.T) {
+	var got []interface{}
+	target := Fn(func(_ context.Context, e myEnum, d time.Duration, f float64, s []string, v ...string) error {
+		got = []interface{}{e, d, f, s, v}
+		return nil
+	}, myEnum("prod"), time.Second, 0.5, []string{"a"}, []string{"b", "c"})
+	if err := target.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	expected := []interface{}{myEnum("prod"), time.Second, 0.5, []string{"a"}, []string{"b", "c"}}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+}
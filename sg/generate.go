@@ -7,17 +7,49 @@ import (
 	"go/parser"
 	"go/token"
 	"os"
+	"reflect"
 
 	"go.einride.tech/sage/internal/codegen"
 )
 
 // GenerateMakefiles defines which Makefiles should be generated.
 func GenerateMakefiles(mks ...Makefile) {
+	files := make([]TaskRunnerFile, 0, len(mks))
+	for _, mk := range mks {
+		files = append(files, mk)
+	}
+	GenerateFiles(files...)
+}
+
+// TaskRunnerFile is a task runner file generated from the sagefiles: a Makefile, Justfile or Taskfile.
+type TaskRunnerFile interface {
+	// makefile returns the namespace, path and default target of the file.
+	makefile() Makefile
+}
+
+// GenerateFiles defines which Makefiles, Justfiles and Taskfiles should be generated.
+// All files invoke the same sagefile binary.
+func GenerateFiles(files ...TaskRunnerFile) {
 	ctx := WithLogger(context.Background(), NewLogger("sage"))
-	Logger(ctx).Println("building binary and generating Makefiles...")
-	if len(mks) == 0 {
+	Logger(ctx).Println("building binary and generating task runner files...")
+	if len(files) == 0 {
 		panic("no makefiles to generate, see https://github.com/einride/sage#readme for more info")
 	}
+	var mks, jfs, tfs []Makefile
+	for _, file := range files {
+		if file.makefile().Path == "" {
+			panic("Path needs to be defined")
+		}
+		switch file.(type) {
+		case Makefile:
+			mks = append(mks, file.makefile())
+		case Justfile:
+			jfs = append(jfs, file.makefile())
+		case Taskfile:
+			tfs = append(tfs, file.makefile())
+		}
+	}
+	namespaces := fileNamespaces(files)
 	pkgs, err := parser.ParseDir(token.NewFileSet(), FromSageDir(), nil, parser.ParseComments)
 	if err != nil {
 		panic(fmt.Errorf("failed to parse directory: %v", err))
@@ -27,7 +59,8 @@ func GenerateMakefiles(mks ...Makefile) {
 	}
 	var pkg *doc.Package
 	for _, p := range pkgs {
-		pkg = doc.New(p, "./", 0)
+		// All declarations are kept, since target parameters may have unexported named types.
+		pkg = doc.New(p, "./", doc.AllDecls)
 	}
 	// update .gitignore file
 	const gitignoreContent = ".gitignore\ntools/\nbin/\nbuild/\n"
@@ -41,7 +74,7 @@ func GenerateMakefiles(mks ...Makefile) {
 		Package:     pkg.Name,
 		GeneratedBy: "go.einride.tech/sage",
 	})
-	if err := generateInitFile(initFile, pkg, mks); err != nil {
+	if err := generateInitFile(initFile, pkg, namespaces); err != nil {
 		panic(err)
 	}
 	initFileContent, err := initFile.GoContent()
@@ -62,9 +95,6 @@ func GenerateMakefiles(mks ...Makefile) {
 	}
 	// Generate makefiles
 	for _, v := range mks {
-		if v.Path == "" {
-			panic("Path needs to be defined")
-		}
 		mk := codegen.NewMakefile(codegen.FileConfig{
 			GeneratedBy: "go.einride.tech/sage",
 		})
@@ -75,4 +105,52 @@ func GenerateMakefiles(mks ...Makefile) {
 			panic(err)
 		}
 	}
+	for _, v := range mks {
+		if v.Completion {
+			if err := generateCompletions(pkg, mks, namespaces); err != nil {
+				panic(err)
+			}
+			break
+		}
+	}
+	// Generate justfiles
+	for _, v := range jfs {
+		jf := codegen.NewMakefile(codegen.FileConfig{
+			GeneratedBy: "go.einride.tech/sage",
+		})
+		if err := generateJustfile(ctx, jf, pkg, v, jfs...); err != nil {
+			panic(err)
+		}
+		if err := os.WriteFile(v.Path, jf.RawContent(), 0o600); err != nil {
+			panic(err)
+		}
+	}
+	// Generate taskfiles
+	for _, v := range tfs {
+		tf := codegen.NewMakefile(codegen.FileConfig{
+			GeneratedBy: "go.einride.tech/sage",
+		})
+		if err := generateTaskfile(ctx, tf, pkg, v, tfs...); err != nil {
+			panic(err)
+		}
+		if err := os.WriteFile(v.Path, tf.RawContent(), 0o600); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// fileNamespaces returns the files as Makefiles with distinct namespaces, to generate the sagefile targets for.
+func fileNamespaces(files []TaskRunnerFile) []Makefile {
+	var result []Makefile
+FileLoop:
+	for _, file := range files {
+		mk := file.makefile()
+		for _, namespace := range result {
+			if reflect.DeepEqual(namespace.Namespace, mk.Namespace) {
+				continue FileLoop
+			}
+		}
+		result = append(result, mk)
+	}
+	return result
 }
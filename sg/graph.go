@@ -0,0 +1,165 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DependencyGraph records the targets that calls to Deps would run, instead of running them.
+//
+// Attach a DependencyGraph to a context with WithDependencyGraph to put it in dry-run mode. In dry-run mode, Deps
+// records its dependencies and walks each of them once, to record their dependencies in turn. Targets are walked
+// serially and without caching, retries, timeouts or locks, Command returns commands that do nothing, and tools are
+// not installed. Targets with other side effects should skip them when IsDryRun reports true.
+//
+// Each call to Deps from the same target is a stage, and the dependencies of a stage only run after the previous stage
+// has completed.
+type DependencyGraph struct {
+	mu      sync.Mutex
+	nodes   []string
+	ids     map[string]int
+	edges   []dependencyEdge
+	stages  map[int]int
+	visited map[int]bool
+}
+
+type dependencyEdge struct {
+	from, to int
+	stage    int
+}
+
+// NewDependencyGraph creates a new DependencyGraph for the target with the provided ID.
+func NewDependencyGraph(rootID string) *DependencyGraph {
+	g := &DependencyGraph{ids: map[string]int{}, stages: map[int]int{}, visited: map[int]bool{}}
+	g.visited[g.node(rootID)] = true
+	return g
+}
+
+type dependencyGraphContextKey struct{}
+
+// WithDependencyGraph attaches a DependencyGraph to the provided context, which puts it in dry-run mode.
+func WithDependencyGraph(ctx context.Context, graph *DependencyGraph) context.Context {
+	return context.WithValue(ctx, dependencyGraphContextKey{}, graph)
+}
+
+func getDependencyGraph(ctx context.Context) *DependencyGraph {
+	graph, _ := ctx.Value(dependencyGraphContextKey{}).(*DependencyGraph)
+	return graph
+}
+
+// IsDryRun reports if ctx is in dry-run mode, where targets run only to record their dependencies.
+func IsDryRun(ctx context.Context) bool {
+	return getDependencyGraph(ctx) != nil
+}
+
+// node returns the index of the node with the provided ID, adding it if needed. The caller must hold g.mu, unless g
+// is being created.
+func (g *DependencyGraph) node(id string) int {
+	if i, ok := g.ids[id]; ok {
+		return i
+	}
+	g.nodes = append(g.nodes, id)
+	g.ids[id] = len(g.nodes) - 1
+	return len(g.nodes) - 1
+}
+
+// walk records a stage of dependencies of the target running with ctx, and runs each dependency that hasn't been
+// walked before, to record its dependencies.
+func (g *DependencyGraph) walk(ctx context.Context, targets []Target) {
+	for _, target := range g.record(ctx, targets) {
+		ctx := WithLogger(withDependency(ctx, target), NewLogger(target.Name()))
+		// Run the innermost Target, skipping decorators such as caching, retries and timeouts.
+		for {
+			wrapper, ok := target.(interface{ Unwrap() Target })
+			if !ok {
+				break
+			}
+			target = wrapper.Unwrap()
+		}
+		if err := runDry(ctx, target); err != nil {
+			Logger(ctx).Printf("dry run: %v", err)
+		}
+	}
+}
+
+// runDry runs the target in a dry run, and returns the error of a panic, so that the rest of the graph is recorded.
+func runDry(ctx context.Context, target Target) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %w", recoveredError(v))
+		}
+	}()
+	return target.Run(ctx)
+}
+
+// record a stage of dependencies of the target running with ctx, and return the dependencies not visited before.
+func (g *DependencyGraph) record(ctx context.Context, targets []Target) []Target {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	from := 0
+	if dependencies := getDependencies(ctx); len(dependencies) > 0 {
+		from = g.node(dependencies[len(dependencies)-1].ID())
+	}
+	g.stages[from]++
+	var unvisited []Target
+	for _, target := range targets {
+		to := g.node(target.ID())
+		g.edges = append(g.edges, dependencyEdge{from: from, to: to, stage: g.stages[from]})
+		if !g.visited[to] {
+			g.visited[to] = true
+			unvisited = append(unvisited, target)
+		}
+	}
+	return unvisited
+}
+
+// WriteDOT writes the graph in the Graphviz DOT format.
+// Edges are labeled with the stage in which the dependency runs.
+func (g *DependencyGraph) WriteDOT(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var b strings.Builder
+	b.WriteString("digraph sage {\n")
+	b.WriteString("\trankdir=LR;\n")
+	for i, id := range g.nodes {
+		_, _ = fmt.Fprintf(&b, "\tn%d [label=%q];\n", i, graphLabel(id))
+	}
+	for _, edge := range g.edges {
+		_, _ = fmt.Fprintf(&b, "\tn%d -> n%d [label=\"%d\"];\n", edge.from, edge.to, edge.stage)
+	}
+	b.WriteString("}\n")
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("write DOT graph: %w", err)
+	}
+	return nil
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart.
+// Edges are labeled with the stage in which the dependency runs.
+func (g *DependencyGraph) WriteMermaid(w io.Writer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var b strings.Builder
+	b.WriteString("flowchart LR\n")
+	for i, id := range g.nodes {
+		_, _ = fmt.Fprintf(&b, "\tn%d[\"%s\"]\n", i, strings.ReplaceAll(graphLabel(id), `"`, "#quot;"))
+	}
+	for _, edge := range g.edges {
+		_, _ = fmt.Fprintf(&b, "\tn%d -->|%d| n%d\n", edge.from, edge.stage, edge.to)
+	}
+	if _, err := io.WriteString(w, b.String()); err != nil {
+		return fmt.Errorf("write Mermaid graph: %w", err)
+	}
+	return nil
+}
+
+// graphLabel returns a readable label for the Target ID.
+func graphLabel(id string) string {
+	id = strings.TrimPrefix(id, "main.")
+	id = strings.TrimPrefix(id, "go.einride.tech/sage/tools/")
+	id = strings.Replace(id, "-fm(", "(", 1)
+	return strings.TrimSuffix(id, "(null)")
+}
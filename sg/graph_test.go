@@ -0,0 +1,144 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDependencyGraph(t *testing.T) {
+	graph := NewDependencyGraph("Default")
+	ctx := WithDependencyGraph(context.Background(), graph)
+	Deps(ctx, MyFunc, Fn(graphWithArg, "value"))
+	SerialDeps(ctx, namespace.MyFunc)
+	var dot strings.Builder
+	if err := graph.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	const expectedDOT = `digraph sage {
+	rankdir=LR;
+	n0 [label="Default"];
+	n1 [label="go.einride.tech/sage/sg.MyFunc"];
+	n2 [label="go.einride.tech/sage/sg.graphWithArg([\"value\"])"];
+	n3 [label="go.einride.tech/sage/sg.namespace.MyFunc"];
+	n0 -> n1 [label="1"];
+	n0 -> n2 [label="1"];
+	n0 -> n3 [label="2"];
+}
+`
+	if dot.String() != expectedDOT {
+		t.Errorf("expected\n%s\nbut got\n%s", expectedDOT, dot.String())
+	}
+	var mermaid strings.Builder
+	if err := graph.WriteMermaid(&mermaid); err != nil {
+		t.Fatal(err)
+	}
+	const expectedMermaid = `flowchart LR
+	n0["Default"]
+	n1["go.einride.tech/sage/sg.MyFunc"]
+	n2["go.einride.tech/sage/sg.graphWithArg([#quot;value#quot;])"]
+	n3["go.einride.tech/sage/sg.namespace.MyFunc"]
+	n0 -->|1| n1
+	n0 -->|1| n2
+	n0 -->|2| n3
+`
+	if mermaid.String() != expectedMermaid {
+		t.Errorf("expected\n%s\nbut got\n%s", expectedMermaid, mermaid.String())
+	}
+}
+
+func TestDependencyGraph_transitive(t *testing.T) {
+	graph := NewDependencyGraph(Fn(graphRoot).ID())
+	ctx := WithDependencyGraph(context.Background(), graph)
+	graphChildRuns = 0
+	if err := graphRoot(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if graphChildRuns != 1 {
+		t.Errorf("expected child to be walked once, but was walked %d times", graphChildRuns)
+	}
+	var dot strings.Builder
+	if err := graph.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	const expectedDOT = `digraph sage {
+	rankdir=LR;
+	n0 [label="go.einride.tech/sage/sg.graphRoot"];
+	n1 [label="go.einride.tech/sage/sg.graphParent"];
+	n2 [label="go.einride.tech/sage/sg.graphChild"];
+	n0 -> n1 [label="1"];
+	n0 -> n2 [label="1"];
+	n1 -> n2 [label="1"];
+}
+`
+	if dot.String() != expectedDOT {
+		t.Errorf("expected\n%s\nbut got\n%s", expectedDOT, dot.String())
+	}
+}
+
+func graphWithArg(_ context.Context, _ string) error {
+	return nil
+}
+
+func graphRoot(ctx context.Context) error {
+	Deps(ctx, graphParent, WithRetry(graphChild, 3, 0))
+	return nil
+}
+
+func graphParent(ctx context.Context) error {
+	Deps(ctx, graphChild)
+	return nil
+}
+
+var graphChildRuns int
+
+func graphChild(ctx context.Context) error {
+	graphChildRuns++
+	if !IsDryRun(ctx) {
+		return fmt.Errorf("expected dry run")
+	}
+	return Command(ctx, "false").Run()
+}
+
+func TestDependencyGraph_panic(t *testing.T) {
+	graph := NewDependencyGraph("Default")
+	ctx := WithDependencyGraph(context.Background(), graph)
+	Deps(ctx, graphPanic, MyFunc)
+	var dot strings.Builder
+	if err := graph.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(dot.String(), "sg.graphPanic") || !strings.Contains(dot.String(), "sg.MyFunc") {
+		t.Errorf("expected the graph to be recorded, but got\n%s", dot.String())
+	}
+}
+
+func TestCommand_dryRunWithoutNoop(t *testing.T) {
+	// Only git is found in the path, which is used to find the git root.
+	git, err := exec.LookPath("git")
+	if err != nil {
+		t.Skip(err)
+	}
+	binDir := t.TempDir()
+	if err := os.Symlink(git, filepath.Join(binDir, "git")); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", binDir)
+	file := filepath.Join(t.TempDir(), "ran")
+	ctx := WithDependencyGraph(context.Background(), NewDependencyGraph("Default"))
+	cmd := Command(ctx, "/bin/sh", "-c", "echo > "+file)
+	if err := cmd.Run(); err == nil {
+		t.Error("expected error")
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected command not to run, but got %v", err)
+	}
+}
+
+func graphPanic(_ context.Context) error {
+	panic("boom")
+}
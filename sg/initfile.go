@@ -7,41 +7,122 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"go.einride.tech/sage/internal/codegen"
-)
-
-const (
-	stringType = "string"
-	intType    = "int"
-	boolType   = "bool"
+	"go.einride.tech/sage/internal/strcase"
 )
 
 func generateInitFile(g *codegen.File, pkg *doc.Package, mks []Makefile) error {
+	if err := checkTargetParams(pkg); err != nil {
+		return err
+	}
 	g.P("func init() {")
 	g.P("ctx := ", g.Import("context"), ".Background()")
-	g.P("if len(", g.Import("os"), ".Args) < 2 {")
-	g.P(g.Import("fmt"), `.Println("Targets:")`)
+	g.P("if len(", g.Import("os"), `.Args) < 2 || os.Args[1] == "--help" || os.Args[1] == "-h" {`)
+	var targetList strings.Builder
+	targetList.WriteString(
+		"Usage: sagefile [--dry-run[=dot|mermaid]] [--watch] [--output=stream|grouped] <target> [arguments...]\n\n",
+	)
+	targetList.WriteString("Targets:\n")
+	tw := tabwriter.NewWriter(&targetList, 0, 8, 2, ' ', 0)
 	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
 		// If function namespace is not part of the to be generated Makefiles, skip it.
 		if skipFunction, _ := shouldBeGenerated(mks, function.Recv); !skipFunction {
 			return
 		}
-		g.P(g.Import("fmt"), `.Println("\t`, getTargetFunctionName(function), `")`)
+		_, _ = fmt.Fprintf(tw, "  %s\t%s\n", getTargetFunctionName(function), doc.Synopsis(function.Doc))
 	})
+	_ = tw.Flush()
+	// Trim the padding of targets without documentation.
+	lines := strings.Split(targetList.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	targetList.Reset()
+	targetList.WriteString(strings.Join(lines, "\n"))
+	targetList.WriteString("\nRun 'sagefile <target> --help' for the arguments of a target.\n")
+	g.P(g.Import("fmt"), ".Print(", strconv.Quote(targetList.String()), ")")
 	g.P(g.Import("os"), ".Exit(0)")
 	g.P("}")
-	g.P("target, args := ", g.Import("os"), ".Args[1], ", g.Import("os"), ".Args[2:]")
-	g.P("_ = args")
-	g.P("var err error")
+	g.P(`if trace, ok := `, g.Import("os"), `.LookupEnv("SAGE_TRACE"); ok {`)
+	g.P("if enabled, _ := ", g.Import("strconv"), ".ParseBool(trace); enabled {")
+	g.P("tracer := ", g.Import("go.einride.tech/sage/sg"), ".NewTracer()")
+	g.P("ctx = ", g.Import("go.einride.tech/sage/sg"), ".WithTracer(ctx, tracer)")
+	g.P(g.Import("go.einride.tech/sage/sg"), ".AtExit(func() {")
+	g.P(
+		"if err := tracer.WriteChromeTraceFile(",
+		g.Import("go.einride.tech/sage/sg"), `.FromBuildDir("trace.json")); err != nil {`,
+	)
+	g.P(g.Import("go.einride.tech/sage/sg"), `.NewLogger("sagefile").Println(err)`)
+	g.P("}")
+	g.P("})")
+	g.P("}")
+	g.P("}")
+	g.P(`if path := `, g.Import("os"), `.Getenv("SAGE_JUNIT_REPORT"); path != "" {`)
+	g.P("report := ", g.Import("go.einride.tech/sage/sg"), ".NewReport()")
+	g.P("ctx = ", g.Import("go.einride.tech/sage/sg"), ".WithReport(ctx, report)")
+	g.P(g.Import("go.einride.tech/sage/sg"), ".AtExit(func() {")
+	g.P("if err := report.WriteJUnitFile(path); err != nil {")
+	g.P(g.Import("go.einride.tech/sage/sg"), `.NewLogger("sagefile").Println(err)`)
+	g.P("}")
+	g.P("})")
+	g.P("}")
+	g.P("logger := ", g.Import("go.einride.tech/sage/sg"), `.NewLogger("sagefile")`)
+	g.P("args := ", g.Import("os"), ".Args[1:]")
+	g.P("var watch bool")
+	g.P("var graphFormat string")
+	g.P(`for len(args) > 0 && `, g.Import("strings"), `.HasPrefix(args[0], "--") {`)
+	g.P("option := args[0]")
+	g.P("args = args[1:]")
+	g.P("switch {")
+	g.P(`case option == "--watch":`)
+	g.P("watch = true")
+	g.P(`case option == "--dry-run" || strings.HasPrefix(option, "--dry-run="):`)
+	g.P(`graphFormat = strings.TrimPrefix(strings.TrimPrefix(option, "--dry-run"), "=")`)
+	g.P(`if graphFormat == "" {`)
+	g.P(`graphFormat = "dot"`)
+	g.P("}")
+	g.P(`if graphFormat != "dot" && graphFormat != "mermaid" {`)
+	g.P(`logger.Fatalf("unsupported dry run format %q, expected dot or mermaid", graphFormat)`)
+	g.P("}")
+	g.P(`case strings.HasPrefix(option, "--output="):`)
+	g.P("mode := ", g.Import("go.einride.tech/sage/sg"), `.OutputMode(strings.TrimPrefix(option, "--output="))`)
+	g.P(
+		"if mode != ", g.Import("go.einride.tech/sage/sg"), ".OutputStream && mode != ",
+		g.Import("go.einride.tech/sage/sg"), ".OutputGrouped {",
+	)
+	g.P(`logger.Fatalf("unsupported output mode %q, expected stream or grouped", mode)`)
+	g.P("}")
+	g.P("ctx = ", g.Import("go.einride.tech/sage/sg"), ".WithOutputMode(ctx, mode)")
+	g.P("default:")
+	g.P(`logger.Fatalf("unknown option %s", option)`)
+	g.P("}")
+	g.P("}")
+	g.P("if len(args) == 0 {")
+	g.P(`logger.Fatal("missing target")`)
+	g.P("}")
+	g.P("target, args := args[0], args[1:]")
 	g.P("switch target {")
+	caseNames := map[string]bool{}
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if skipFunction, _ := shouldBeGenerated(mks, function.Recv); skipFunction {
+			caseNames[getTargetFunctionName(function)] = true
+		}
+	})
 	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
 		// If function namespace is not part of the to be generated Makefiles, skip it.
 		skipFunction, nsStruct := shouldBeGenerated(mks, function.Recv)
 		if !skipFunction {
 			return
 		}
-		g.P(`case "`, getTargetFunctionName(function), `":`)
+		names := []string{strconv.Quote(getTargetFunctionName(function))}
+		// Also accept the kebab-case name of the target, as used by make.
+		if alias := toKebabTarget(getTargetFunctionName(function)); !caseNames[alias] {
+			caseNames[alias] = true
+			names = append(names, strconv.Quote(alias))
+		}
+		g.P(`case `, strings.Join(names, ", "), `:`)
 		loggerName := getTargetFunctionName(function)
 		// Remove namespace from loggerName
 		if strings.Contains(loggerName, ":") {
@@ -61,65 +142,267 @@ func generateInitFile(g *codegen.File, pkg *doc.Package, mks []Makefile) error {
 		g.P("<-shutdownCh")
 		g.P("cancel()")
 		g.P("}()")
-		if len(function.Decl.Type.Params.List) > 1 {
-			expected := countParams(function.Decl.Type.Params.List) - 1
-			g.P("if len(args) != ", expected, " {")
-			g.P(
-				`logger.Fatalf("wrong number of arguments to %s, got %v expected %v",`,
-				strconv.Quote(getTargetFunctionName(function)), ",",
-				expected, ",",
-				`len(args))`,
-			)
-			g.P(g.Import("os"), ".Exit(1)")
-			g.P("}")
-			var args []string
-			var i int
-			for _, customParam := range function.Decl.Type.Params.List[1:] {
-				for range customParam.Names {
-					args = append(args, fmt.Sprintf("arg%v", i))
-					switch fmt.Sprint(customParam.Type) {
-					case stringType:
-						g.P("arg", i, " := args[", i, "]")
-					case intType:
-						g.P("arg", i, ", err :=", g.Import("strconv"), ".Atoi(args[", i, "])")
-						g.P("if err != nil {")
-						g.P(`logger.Fatalf("can't convert argument %q to int", args[`, i, `])`)
-						g.P("}")
-					case boolType:
-						g.P("arg", i, ", err :=", g.Import("strconv"), ".ParseBool(args[", i, "])")
-						g.P("if err != nil {")
-						g.P(`logger.Fatalf("can't convert argument to bool", args[`, i, `])`)
-						g.P("}")
-					}
-					i++
-				}
-			}
-			g.P(
-				"err = ",
-				strings.ReplaceAll(getTargetFunctionName(function), ":", nsStruct),
-				"(ctx,",
-				strings.Join(args, ","),
-				")",
-			)
-			g.P("if err != nil {")
-			g.P("logger.Fatal(err)")
-			g.P("}")
-		} else {
-			g.P("err = ", strings.ReplaceAll(getTargetFunctionName(function), ":", nsStruct), "(ctx)")
-			g.P("if err != nil {")
-			g.P("logger.Fatal(err)")
-			g.P("}")
+		params := targetParams(pkg, function)
+		flags := make([]string, 0, len(params))
+		bools := make([]string, 0, len(params))
+		for _, param := range params {
+			flags = append(flags, strconv.Quote(toFlag(param)))
+			bools = append(bools, strconv.FormatBool(param.typ == boolType))
+		}
+		g.P("usage := ", strconv.Quote(targetUsage(function, params)))
+		resolvedArgs := "args"
+		if len(params) == 0 {
+			resolvedArgs = "_"
 		}
+		g.P(
+			resolvedArgs, ", help, err := sageResolveArgs(args, []string{", strings.Join(flags, ", "), "}, []bool{",
+			strings.Join(bools, ", "), "}, ", len(params) > 0 && params[len(params)-1].variadic, ")",
+		)
+		g.P("if help {")
+		g.P(g.Import("fmt"), ".Print(usage)")
+		g.P(g.Import("os"), ".Exit(0)")
+		g.P("}")
+		g.P("if err != nil {")
+		g.P("logger.Println(err)")
+		g.P(g.Import("fmt"), ".Fprint(", g.Import("os"), ".Stderr, usage)")
+		g.P(g.Import("os"), ".Exit(1)")
+		g.P("}")
+		// The target is run as a Target, which roots the dependency graph and the report at the same ID as Deps.
+		fnArgs := []string{strings.ReplaceAll(getTargetFunctionName(function), ":", nsStruct)}
+		for i, param := range params {
+			fnArgs = append(fnArgs, fmt.Sprintf("arg%v", i))
+			generateParseArg(g, i, param)
+		}
+		g.P("fn := ", g.Import("go.einride.tech/sage/sg"), ".Fn(", strings.Join(fnArgs, ", "), ")")
+		g.P(`if graphFormat != "" {`)
+		g.P("ctx = sageDryRun(ctx, fn, graphFormat)")
+		g.P("}")
+		g.P("run := func(ctx context.Context) error {")
+		g.P("return ", g.Import("go.einride.tech/sage/sg"), ".ReportTarget(ctx, fn)")
+		g.P("}")
+		g.P("if watch {")
+		g.P("err = ", g.Import("go.einride.tech/sage/sg"), ".Watch(ctx, run)")
+		g.P("} else {")
+		g.P("err = run(ctx)")
+		g.P("}")
+		g.P("if err != nil {")
+		g.P("logger.Println(err)")
+		g.P(g.Import("go.einride.tech/sage/sg"), ".Exit(1)")
+		g.P("}")
 	})
 	g.P("default:")
 	g.P("logger := ", g.Import("go.einride.tech/sage/sg"), ".NewLogger(\"sagefile\")")
 	g.P(`logger.Fatalf("unknown target specified: %s", target)`)
 	g.P("}")
-	g.P(g.Import("os"), ".Exit(0)")
+	g.P(g.Import("go.einride.tech/sage/sg"), ".Exit(0)")
 	g.P("}")
+	g.P()
+	generateResolveArgs(g)
+	g.P()
+	generateDryRun(g)
 	return nil
 }
 
+// generateDryRun generates a function that puts the context of a target in dry-run mode, and writes its dependency
+// graph in the provided format on exit.
+func generateDryRun(g *codegen.File) {
+	g.P(
+		"func sageDryRun(ctx ", g.Import("context"), ".Context, target ", g.Import("go.einride.tech/sage/sg"),
+		".Target, format string) context.Context {",
+	)
+	g.P("graph := ", g.Import("go.einride.tech/sage/sg"), ".NewDependencyGraph(target.ID())")
+	g.P(g.Import("go.einride.tech/sage/sg"), ".AtExit(func() {")
+	g.P("write := graph.WriteDOT")
+	g.P(`if format == "mermaid" {`)
+	g.P("write = graph.WriteMermaid")
+	g.P("}")
+	g.P("if err := write(", g.Import("os"), ".Stdout); err != nil {")
+	g.P(g.Import("go.einride.tech/sage/sg"), `.NewLogger("sagefile").Println(err)`)
+	g.P("}")
+	g.P("})")
+	g.P("return ", g.Import("go.einride.tech/sage/sg"), ".WithDependencyGraph(ctx, graph)")
+	g.P("}")
+}
+
+// generateResolveArgs generates a function that resolves the named flags and positional arguments of a target to
+// one argument per parameter, in parameter order. Positional arguments fill the parameters not set by flags.
+func generateResolveArgs(g *codegen.File) {
+	g.P("func sageResolveArgs(args []string, flags []string, bools []bool, variadic bool) ([]string, bool, error) {")
+	g.P("named := make(map[int][]string, len(flags))")
+	g.P("var positional []string")
+	g.P("for i := 0; i < len(args); i++ {")
+	g.P("arg := args[i]")
+	g.P("switch {")
+	g.P(`case arg == "--":`)
+	g.P("positional = append(positional, args[i+1:]...)")
+	g.P("i = len(args)")
+	g.P("continue")
+	g.P(`case arg == "--help" || arg == "-h":`)
+	g.P("return nil, true, nil")
+	g.P(`case !`, g.Import("strings"), `.HasPrefix(arg, "--"):`)
+	g.P("positional = append(positional, arg)")
+	g.P("continue")
+	g.P("}")
+	g.P(`name, value := strings.TrimPrefix(arg, "--"), ""`)
+	g.P(`hasValue := strings.Contains(name, "=")`)
+	g.P("if hasValue {")
+	g.P(`value = name[strings.Index(name, "=")+1:]`)
+	g.P(`name = name[:strings.Index(name, "=")]`)
+	g.P("}")
+	g.P("index := -1")
+	g.P("for j, flag := range flags {")
+	g.P(`if name == flag || name == strings.ReplaceAll(flag, "-", "_") {`)
+	g.P("index = j")
+	g.P("}")
+	g.P("}")
+	g.P("switch {")
+	g.P("case index == -1:")
+	g.P("return nil, false, ", g.Import("fmt"), `.Errorf("unknown flag --%s", name)`)
+	g.P("case !hasValue && bools[index]:")
+	g.P(`value = "true"`)
+	g.P("case !hasValue && i+1 < len(args):")
+	g.P("i++")
+	g.P("value = args[i]")
+	g.P("case !hasValue:")
+	g.P(`return nil, false, fmt.Errorf("missing value for flag --%s", name)`)
+	g.P("}")
+	g.P("if variadic && index == len(flags)-1 {")
+	g.P("named[index] = append(named[index], value)")
+	g.P("} else {")
+	g.P("named[index] = []string{value}")
+	g.P("}")
+	g.P("}")
+	g.P("resolved := make([]string, 0, len(flags))")
+	g.P("numPositional := len(positional)")
+	g.P("for i, flag := range flags {")
+	g.P("if variadic && i == len(flags)-1 {")
+	g.P("resolved = append(resolved, named[i]...)")
+	g.P("resolved = append(resolved, positional...)")
+	g.P("return resolved, false, nil")
+	g.P("}")
+	g.P("if value, ok := named[i]; ok {")
+	g.P("resolved = append(resolved, value[0])")
+	g.P("continue")
+	g.P("}")
+	g.P("if len(positional) == 0 {")
+	g.P(`return nil, false, fmt.Errorf("missing argument --%s", flag)`)
+	g.P("}")
+	g.P("resolved = append(resolved, positional[0])")
+	g.P("positional = positional[1:]")
+	g.P("}")
+	g.P("if len(positional) > 0 {")
+	g.P(
+		`return nil, false, fmt.Errorf("too many arguments, got %d expected %d", numPositional, `,
+		`numPositional-len(positional))`,
+	)
+	g.P("}")
+	g.P("return resolved, false, nil")
+	g.P("}")
+}
+
+// targetUsage returns the usage text of a target, listing the documentation and the parameters of the function.
+func targetUsage(function *doc.Func, params []targetParam) string {
+	var b strings.Builder
+	b.WriteString("Usage: sagefile ")
+	b.WriteString(getTargetFunctionName(function))
+	for _, param := range params {
+		if param.variadic {
+			_, _ = fmt.Fprintf(&b, " [[--%s=]<%s>...]", toFlag(param), param.name)
+			continue
+		}
+		_, _ = fmt.Fprintf(&b, " [--%s=]<%s>", toFlag(param), param.name)
+	}
+	b.WriteString("\n")
+	if function.Doc != "" {
+		b.WriteString("\n")
+		b.WriteString(function.Doc)
+	}
+	if len(params) > 0 {
+		b.WriteString("\nArguments:\n")
+		tw := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+		for _, param := range params {
+			_, _ = fmt.Fprintf(tw, "  --%s\t%s\n", toFlag(param), paramDescription(param))
+		}
+		_ = tw.Flush()
+	}
+	return b.String()
+}
+
+// paramDescription returns a description of the type of a target parameter, for usage texts.
+func paramDescription(param targetParam) string {
+	switch {
+	case param.variadic:
+		return "...string (repeatable)"
+	case len(param.values) > 0:
+		return fmt.Sprintf("%s (one of: %s)", param.typ, strings.Join(param.values, ", "))
+	case param.typ == stringSliceType:
+		return "[]string (comma-separated)"
+	default:
+		return param.typ
+	}
+}
+
+// toFlag converts a target parameter to a command line flag name, without dashes.
+func toFlag(param targetParam) string {
+	return strings.ToLower(strcase.ToKebab(param.name))
+}
+
+// toKebabTarget converts a target function name to kebab case, keeping any namespace.
+func toKebabTarget(name string) string {
+	parts := strings.Split(name, ":")
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strcase.ToKebab(part))
+	}
+	return strings.Join(parts, ":")
+}
+
+// generateParseArg generates code parsing the i:th argument into the variable arg<i>.
+func generateParseArg(g *codegen.File, i int, param targetParam) {
+	switch {
+	case param.variadic:
+		g.P("arg", i, " := args[", i, ":]")
+	case param.named:
+		g.P("arg", i, " := ", param.typ, "(args[", i, "])")
+		if len(param.values) > 0 {
+			quoted := make([]string, 0, len(param.values))
+			for _, value := range param.values {
+				quoted = append(quoted, strconv.Quote(value))
+			}
+			g.P("switch arg", i, " {")
+			g.P("case ", strings.Join(quoted, ", "), ":")
+			g.P("default:")
+			g.P(
+				`logger.Fatalf("invalid value %q for argument `, param.name, `, allowed values are: %s", args[`, i, `], `,
+				strconv.Quote(strings.Join(param.values, ", ")), ")",
+			)
+			g.P("}")
+		}
+	case param.typ == stringType:
+		g.P("arg", i, " := args[", i, "]")
+	case param.typ == stringSliceType:
+		g.P("var arg", i, " []string")
+		g.P("if args[", i, `] != "" {`)
+		g.P("arg", i, " = ", g.Import("strings"), ".Split(args[", i, `], ",")`)
+		g.P("}")
+	default:
+		switch param.typ {
+		case intType:
+			g.P("arg", i, ", err := ", g.Import("strconv"), ".Atoi(args[", i, "])")
+		case boolType:
+			g.P("arg", i, ", err := ", g.Import("strconv"), ".ParseBool(args[", i, "])")
+		case float64Type:
+			g.P("arg", i, ", err := ", g.Import("strconv"), ".ParseFloat(args[", i, "], 64)")
+		case durationType:
+			g.P("arg", i, ", err := ", g.Import("time"), ".ParseDuration(args[", i, "])")
+		}
+		g.P("if err != nil {")
+		g.P(
+			`logger.Fatalf("can't convert argument `, param.name, ` %q to `, param.typ, `: %v", args[`, i, `], err)`,
+		)
+		g.P("}")
+	}
+}
+
 // shouldBeGenerated returns true if the namespace equals any of the namespaces in the to be generated Makefiles and
 // returns any metadata the namespace might have.
 func shouldBeGenerated(mks []Makefile, namespace string) (bool, string) {
@@ -150,14 +433,6 @@ func shouldBeGenerated(mks []Makefile, namespace string) (bool, string) {
 	return partOfMakefile, namespaceStruct
 }
 
-func countParams(fields []*ast.Field) int {
-	var result int
-	for _, field := range fields {
-		result += len(field.Names)
-	}
-	return result
-}
-
 func getTargetFunctionName(function *doc.Func) string {
 	var result strings.Builder
 	if function.Recv != "" {
@@ -172,7 +447,7 @@ func forEachTargetFunction(pkg *doc.Package, fn func(function *doc.Func, namespa
 	for _, function := range pkg.Funcs {
 		if function.Recv != "" ||
 			!ast.IsExported(function.Name) ||
-			!isSupportedTargetFunctionParams(function.Decl.Type.Params.List) {
+			!isTargetFunctionParams(function.Decl.Type.Params.List) {
 			continue
 		}
 		fn(function, nil)
@@ -183,7 +458,7 @@ func forEachTargetFunction(pkg *doc.Package, fn func(function *doc.Func, namespa
 		}
 		for _, function := range namespace.Methods {
 			if !ast.IsExported(function.Name) ||
-				!isSupportedTargetFunctionParams(function.Decl.Type.Params.List) {
+				!isTargetFunctionParams(function.Decl.Type.Params.List) {
 				continue
 			}
 			fn(function, nil)
@@ -191,28 +466,9 @@ func forEachTargetFunction(pkg *doc.Package, fn func(function *doc.Func, namespa
 	}
 }
 
-func isSupportedTargetFunctionParams(params []*ast.Field) bool {
-	if len(params) == 0 {
-		return false
-	}
-	if !isContextParam(params[0]) {
-		return false
-	}
-	for _, customParam := range params[1:] {
-		if !isSupportedCustomParam(customParam) {
-			return false
-		}
-	}
-	return true
-}
-
-func isSupportedCustomParam(arg *ast.Field) bool {
-	switch fmt.Sprint(arg.Type) {
-	case stringType, intType, boolType:
-		return true
-	default:
-		return false
-	}
+// isTargetFunctionParams reports if params are the parameters of a target function, which takes a context first.
+func isTargetFunctionParams(params []*ast.Field) bool {
+	return len(params) > 0 && isContextParam(params[0])
 }
 
 func isContextParam(param *ast.Field) bool {
@@ -0,0 +1,23 @@
+package runner
+
+import "sync"
+
+// global state for named locks.
+//
+//nolint:gochecknoglobals
+var (
+	locksMu sync.Mutex
+	locks   = map[string]*Semaphore{}
+)
+
+// Lock returns the global lock with the provided name.
+func Lock(name string) *Semaphore {
+	locksMu.Lock()
+	defer locksMu.Unlock()
+	lock, ok := locks[name]
+	if !ok {
+		lock = NewSemaphore(1)
+		locks[name] = lock
+	}
+	return lock
+}
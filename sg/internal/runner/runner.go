@@ -35,3 +35,10 @@ func makeOnceFn(fn func(context.Context) error) func(context.Context) error {
 		return err
 	}
 }
+
+// Reset forgets all functions run with RunOnce, so that they run again.
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+	onceFns = map[string]func(context.Context) error{}
+}
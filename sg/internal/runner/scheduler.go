@@ -0,0 +1,135 @@
+package runner
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+// global state for the scheduler.
+//
+//nolint:gochecknoglobals
+var (
+	schedulerOnce sync.Once
+	scheduler     *Semaphore
+)
+
+// Acquire blocks until weight units of the global parallelism limit are available or ctx is done.
+//
+// The global parallelism limit is read from the environment variable SAGE_MAX_PARALLEL, and defaults to the number
+// of CPUs. A weight larger than the limit is reduced to the limit, so that heavy targets can still run alone.
+func Acquire(ctx context.Context, weight int) error {
+	return globalScheduler().Acquire(ctx, weight)
+}
+
+// Release returns weight units to the global parallelism limit.
+func Release(weight int) {
+	globalScheduler().Release(weight)
+}
+
+func globalScheduler() *Semaphore {
+	schedulerOnce.Do(func() {
+		size := runtime.NumCPU()
+		if value, ok := os.LookupEnv("SAGE_MAX_PARALLEL"); ok {
+			maxParallel, err := strconv.Atoi(value)
+			if err != nil || maxParallel < 1 {
+				panic(fmt.Sprintf("invalid SAGE_MAX_PARALLEL %q: must be a positive integer", value))
+			}
+			size = maxParallel
+		}
+		scheduler = NewSemaphore(size)
+	})
+	return scheduler
+}
+
+// Semaphore is a weighted semaphore that grants waiters in FIFO order.
+type Semaphore struct {
+	mu      sync.Mutex
+	size    int
+	current int
+	waiters list.List
+}
+
+type waiter struct {
+	weight int
+	ready  chan struct{}
+}
+
+// NewSemaphore creates a new Semaphore with the provided total weight.
+func NewSemaphore(size int) *Semaphore {
+	return &Semaphore{size: size}
+}
+
+// Acquire blocks until weight units are available or ctx is done.
+func (s *Semaphore) Acquire(ctx context.Context, weight int) error {
+	weight = s.clamp(weight)
+	s.mu.Lock()
+	if s.size-s.current >= weight && s.waiters.Len() == 0 {
+		s.current += weight
+		s.mu.Unlock()
+		return nil
+	}
+	ready := make(chan struct{})
+	element := s.waiters.PushBack(waiter{weight: weight, ready: ready})
+	s.mu.Unlock()
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-ready:
+			// Acquired after ctx was done, give the units back.
+			s.current -= weight
+		default:
+			s.waiters.Remove(element)
+		}
+		s.notifyWaiters()
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Release returns weight units to the semaphore.
+func (s *Semaphore) Release(weight int) {
+	weight = s.clamp(weight)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.current -= weight
+	if s.current < 0 {
+		panic("runner: released more than held")
+	}
+	s.notifyWaiters()
+}
+
+func (s *Semaphore) clamp(weight int) int {
+	switch {
+	case weight < 1:
+		return 1
+	case weight > s.size:
+		return s.size
+	default:
+		return weight
+	}
+}
+
+func (s *Semaphore) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+		w := next.Value.(waiter)
+		if s.size-s.current < w.weight {
+			// Don't let smaller waiters overtake, to avoid starving heavy targets.
+			return
+		}
+		s.current += w.weight
+		s.waiters.Remove(next)
+		close(w.ready)
+	}
+}
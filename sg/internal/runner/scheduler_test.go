@@ -0,0 +1,51 @@
+package runner
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSemaphore(t *testing.T) {
+	t.Run("clamp heavy weight", func(t *testing.T) {
+		s := NewSemaphore(2)
+		if err := s.Acquire(context.Background(), 5); err != nil {
+			t.Fatal(err)
+		}
+		s.Release(5)
+	})
+	t.Run("blocks until released", func(t *testing.T) {
+		s := NewSemaphore(2)
+		if err := s.Acquire(context.Background(), 2); err != nil {
+			t.Fatal(err)
+		}
+		acquired := make(chan struct{})
+		go func() {
+			_ = s.Acquire(context.Background(), 1)
+			close(acquired)
+		}()
+		select {
+		case <-acquired:
+			t.Fatal("expected acquire to block")
+		case <-time.After(10 * time.Millisecond):
+		}
+		s.Release(2)
+		<-acquired
+	})
+	t.Run("canceled", func(t *testing.T) {
+		s := NewSemaphore(1)
+		if err := s.Acquire(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if err := s.Acquire(ctx, 1); !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected canceled but got %v", err)
+		}
+		s.Release(1)
+		if err := s.Acquire(context.Background(), 1); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,138 @@
+package sg
+
+import (
+	"context"
+	"go/doc"
+	"path/filepath"
+	"strings"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+// Justfile is a Justfile for the just command runner, generated from the sagefiles.
+// The fields have the same meaning as for a Makefile.
+type Justfile struct {
+	Namespace     interface{}
+	Path          string
+	DefaultTarget interface{}
+}
+
+func (j Justfile) makefile() Makefile {
+	return Makefile{Namespace: j.Namespace, Path: j.Path, DefaultTarget: j.DefaultTarget}
+}
+
+func generateJustfile(_ context.Context, g *codegen.File, pkg *doc.Package, jf Makefile, jfs ...Makefile) error {
+	includePath, err := filepath.Rel(filepath.Dir(jf.Path), FromSageDir())
+	if err != nil {
+		return err
+	}
+	g.P("# To learn more, see ", includePath, "/main.go and https://github.com/einride/sage.")
+	g.P()
+	g.P(`sagefile := justfile_directory() + "/`, filepath.Join(includePath, binDir, sageFileBinary), `"`)
+	g.P()
+	g.P("# Setup Go.")
+	g.P("go := `command -v go 2>/dev/null || true`")
+	g.P(`export GOWORK := env_var_or_default("GOWORK", "off")`)
+	g.P(`sage_go_version := env_var_or_default("SAGE_GO_VERSION", "`, defaultGoVersion, `")`)
+	g.P(
+		`sage_goroot := justfile_directory() + "/`, filepath.Join(includePath, toolsDir, "go"),
+		`/" + sage_go_version + "/go"`,
+	)
+	g.P(`export GOROOT := if go == "" { sage_goroot } else { env_var_or_default("GOROOT", "") }`)
+	g.P(`export PATH := if go == "" { env_var("PATH") + ":" + sage_goroot + "/bin" } else { env_var("PATH") }`)
+	g.P("sage_os := `uname | tr '[:upper:]' '[:lower:]'`")
+	g.P("sage_arch := replace(`uname -m`, \"x86_64\", \"amd64\")")
+	g.P()
+	// The first recipe is the default recipe.
+	if len(jf.defaultTargetName()) != 0 {
+		g.P("_default: ", toMakeTarget(jf.defaultTargetName()))
+	} else {
+		g.P("_default:")
+		g.P("\t@{{just_executable()}} --justfile {{quote(justfile())}} --list")
+	}
+	g.P()
+	g.P("_go:")
+	g.P(
+		`	@command -v go >/dev/null || (echo "installing Go {{sage_go_version}}..."`,
+		` && mkdir -p "$(dirname "$GOROOT")"`,
+		` && curl -sSL https://go.dev/dl/go{{sage_go_version}}.{{sage_os}}-{{sage_arch}}.tar.gz`,
+		` | tar xz -C "$(dirname "$GOROOT")"`,
+		` && touch "$GOROOT/go.mod")`,
+	)
+	g.P()
+	g.P("_sagefile: _go")
+	g.P("\t@cd ", includePath, " && go mod tidy && go run .")
+	g.P()
+	g.P("# Build the sagefile and generate the task runner files.")
+	g.P("sage: _sagefile")
+	g.P()
+	g.P("# Update Sage to the latest version.")
+	g.P("update-sage: _go")
+	g.P("\t@cd ", includePath, " && go get -d go.einride.tech/sage@latest && go mod tidy && go run .")
+	g.P()
+	g.P("# Remove the Sage tools and build files.")
+	g.P("clean-sage:")
+	g.P(
+		"\t@git clean -fdx ",
+		filepath.Join(includePath, toolsDir),
+		" ",
+		filepath.Join(includePath, binDir),
+		" ",
+		filepath.Join(includePath, buildDir),
+	)
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if function.Recv != jf.namespaceName() {
+			return
+		}
+		params := targetParams(pkg, function)
+		recipe := []string{toMakeTarget(getTargetFunctionName(function))}
+		for _, param := range params {
+			// Variadic arguments are optional.
+			if param.variadic {
+				recipe = append(recipe, "*"+toMakeVar(param))
+				continue
+			}
+			recipe = append(recipe, toMakeVar(param))
+		}
+		g.P()
+		if synopsis := doc.Synopsis(function.Doc); synopsis != "" {
+			g.P("# ", synopsis)
+		}
+		g.P(strings.Join(recipe, " "), ": _sagefile")
+		g.P("\t@{{quote(sagefile)}} ", toJustSageFunction(getTargetFunctionName(function), params))
+	})
+	// Add additional justfiles to default justfile
+	if jf.namespaceName() == "" {
+		for _, i := range jfs {
+			if i.namespaceName() == "" {
+				continue
+			}
+			jfPath, err := filepath.Rel(filepath.Dir(jf.Path), i.Path)
+			if err != nil {
+				return err
+			}
+			g.P()
+			g.P("# Run the default recipe of the ", i.namespaceName(), " Justfile.")
+			g.P(toMakeTarget(i.namespaceName()), ":")
+			g.P("\t@{{just_executable()}} --justfile ", jfPath)
+		}
+	}
+	return nil
+}
+
+// toJustSageFunction converts input to a sage Target name with the provided params as recipe parameters.
+// Variadic params are passed unquoted, to split them into separate arguments.
+// The params follow a "--" separator, so that values starting with "-" are not parsed as flags.
+func toJustSageFunction(target string, params []targetParam) string {
+	if len(params) > 0 {
+		target += " --"
+	}
+	for _, param := range params {
+		if param.variadic {
+			target += " {{" + toMakeVar(param) + "}}"
+			continue
+		}
+		target += " {{quote(" + toMakeVar(param) + ")}}"
+	}
+	return target
+}
@@ -0,0 +1,99 @@
+package sg
+
+import (
+	"context"
+	"sort"
+
+	"go.einride.tech/sage/sg/internal/runner"
+)
+
+// WithLock returns a Target that holds the named lock while running.
+//
+// Deps never runs two targets holding the same lock at the same time, while other targets keep running in parallel.
+// Use locks to serialize targets that share a resource, such as the Docker daemon, a port or a process environment
+// variable.
+//
+// Dependencies of a target holding a lock may use the same lock, in which case they are serialized among each other,
+// but not with the target waiting for them. Targets holding multiple locks acquire them in name order, but a
+// dependency acquiring a lock its parent doesn't hold can still deadlock with a target acquiring the locks the other
+// way around.
+func WithLock(name string, target interface{}) Target {
+	return lockedTarget{Target: checkFunctions(target)[0], name: name}
+}
+
+type lockedTarget struct {
+	Target
+	name string
+}
+
+// Locks returns the names of the locks held by the Target.
+func (l lockedTarget) Locks() []string {
+	return []string{l.name}
+}
+
+// Unwrap returns the wrapped Target.
+func (l lockedTarget) Unwrap() Target {
+	return l.Target
+}
+
+// targetLocks returns the sorted names of the locks declared by target and all Targets it wraps.
+func targetLocks(target Target) []string {
+	var result []string
+	for {
+		if locked, ok := target.(interface{ Locks() []string }); ok {
+			result = append(result, locked.Locks()...)
+		}
+		wrapper, ok := target.(interface{ Unwrap() Target })
+		if !ok {
+			break
+		}
+		target = wrapper.Unwrap()
+	}
+	sort.Strings(result)
+	return result
+}
+
+type heldLocksContextKey struct{}
+
+// heldLocks returns the locks held by the running target, by name. Dependencies of the target acquire these locks
+// instead of the global ones.
+func heldLocks(ctx context.Context) map[string]*runner.Semaphore {
+	locks, _ := ctx.Value(heldLocksContextKey{}).(map[string]*runner.Semaphore)
+	return locks
+}
+
+// acquireLocks acquires the locks declared by target and returns a context for running it, and a function that
+// releases the locks.
+func acquireLocks(ctx context.Context, target Target) (context.Context, func(), error) {
+	names := targetLocks(target)
+	parentLocks := heldLocks(ctx)
+	childLocks := make(map[string]*runner.Semaphore, len(parentLocks)+len(names))
+	for name, lock := range parentLocks {
+		childLocks[name] = lock
+	}
+	acquired := make([]*runner.Semaphore, 0, len(names))
+	release := func() {
+		for i := len(acquired) - 1; i >= 0; i-- {
+			acquired[i].Release(1)
+		}
+	}
+	for i, name := range names {
+		if i > 0 && names[i-1] == name {
+			continue
+		}
+		lock, ok := parentLocks[name]
+		if !ok {
+			lock = runner.Lock(name)
+		}
+		if err := lock.Acquire(ctx, 1); err != nil {
+			release()
+			return nil, nil, err
+		}
+		acquired = append(acquired, lock)
+		childLocks[name] = runner.NewSemaphore(1)
+	}
+	if len(acquired) == 0 {
+		return ctx, release, nil
+	}
+	return context.WithValue(ctx, heldLocksContextKey{}, childLocks), release, nil
+}
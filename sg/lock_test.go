@@ -0,0 +1,53 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWithLock(t *testing.T) {
+	t.Run("serialized", func(t *testing.T) {
+		var running, maxRunning int32
+		lockTarget := func(id int) Target {
+			return WithLock("test-serialized", Fn(func(_ context.Context, _ int) error {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+				for {
+					current := atomic.LoadInt32(&maxRunning)
+					if n <= current || atomic.CompareAndSwapInt32(&maxRunning, current, n) {
+						break
+					}
+				}
+				time.Sleep(10 * time.Millisecond)
+				return nil
+			}, id))
+		}
+		if err := DepsE(context.Background(), lockTarget(1), lockTarget(2), lockTarget(3)); err != nil {
+			t.Fatal(err)
+		}
+		if maxRunning != 1 {
+			t.Fatalf("expected at most 1 locked target running but got %d", maxRunning)
+		}
+	})
+	t.Run("nested", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := DepsE(ctx, WithLock("test-nested", lockParent)); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+func lockParent(ctx context.Context) error {
+	return DepsE(ctx, WithLock("test-nested", lockChild))
+}
+
+func lockChild(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("child: %w", ctx.Err())
+	}
+	return nil
+}
@@ -14,18 +14,21 @@ type loggerContextKey struct{}
 
 // NewLogger returns a standard logger.
 func NewLogger(name string) *log.Logger {
-	prefix := name
-	prefix = strings.TrimPrefix(prefix, "main.")
-	prefix = strings.TrimPrefix(prefix, "go.einride.tech/sage/tools/")
+	return log.New(os.Stderr, fmt.Sprintf("[%s] ", loggerName(name)), 0)
+}
+
+// loggerName returns the name of a target or tool as displayed by its logger.
+func loggerName(name string) string {
+	result := name
+	result = strings.TrimPrefix(result, "main.")
+	result = strings.TrimPrefix(result, "go.einride.tech/sage/tools/")
 
 	// Separate namespace and target with colon, expecting the string to be
 	// of the format `namespace.target`.
-	if len(strings.Split(prefix, ".")) > 1 {
-		prefix = strings.Join(strings.Split(prefix, "."), ":")
+	if len(strings.Split(result, ".")) > 1 {
+		result = strings.Join(strings.Split(result, "."), ":")
 	}
-	prefix = strcase.ToKebab(prefix)
-	prefix = fmt.Sprintf("[%s] ", prefix)
-	return log.New(os.Stderr, prefix, 0)
+	return strcase.ToKebab(result)
 }
 
 // WithLogger attaches a log.Logger to the provided context.
@@ -40,9 +43,20 @@ func AppendLoggerPrefix(ctx context.Context, prefix string) context.Context {
 }
 
 // Logger returns the log.Logger attached to ctx, or a default logger.
+//
+// When ctx has secrets, the returned logger replaces them with ***.
 func Logger(ctx context.Context) *log.Logger {
+	logger := NewLogger("sage")
 	if value := ctx.Value(loggerContextKey{}); value != nil {
-		return value.(*log.Logger)
+		logger = value.(*log.Logger)
+	}
+	if secrets := getSecrets(ctx); len(secrets) > 0 {
+		out := logger.Writer()
+		if redacted, ok := out.(redactWriter); ok {
+			// The secrets of ctx include the secrets of the redacted logger.
+			out = redacted.out
+		}
+		return log.New(redactWriter{out: out, secrets: secrets}, logger.Prefix(), logger.Flags())
 	}
-	return NewLogger("sage")
+	return logger
 }
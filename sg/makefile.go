@@ -3,12 +3,12 @@ package sg
 import (
 	"context"
 	"fmt"
-	"go/ast"
 	"go/doc"
 	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
+	"text/tabwriter"
 	"unicode"
 
 	"go.einride.tech/sage/internal/codegen"
@@ -21,6 +21,15 @@ type Makefile struct {
 	Namespace     interface{}
 	Path          string
 	DefaultTarget interface{}
+	// DefaultToHelp makes the generated help target the default target, when DefaultTarget is not set.
+	DefaultToHelp bool
+	// Completion generates bash, zsh and fish completion scripts for the Makefiles and the sagefile into
+	// .sage/build/completion, and adds a sage-completion target that prints the script for the current shell.
+	Completion bool
+}
+
+func (m Makefile) makefile() Makefile {
+	return m
 }
 
 func (m Makefile) namespaceName() string {
@@ -52,9 +61,13 @@ func generateMakefile(_ context.Context, g *codegen.File, pkg *doc.Package, mk M
 		return err
 	}
 	g.P("# To learn more, see ", includePath, "/main.go and https://github.com/einride/sage.")
+	helpTarget := makeHelpTarget(pkg, mk)
 	if len(mk.defaultTargetName()) != 0 {
 		g.P()
 		g.P(".DEFAULT_GOAL := ", toMakeTarget(mk.defaultTargetName()))
+	} else if mk.DefaultToHelp {
+		g.P()
+		g.P(".DEFAULT_GOAL := ", helpTarget)
 	}
 	g.P()
 	g.P("cwd := $(dir $(realpath $(firstword $(MAKEFILE_LIST))))")
@@ -103,22 +116,51 @@ func generateMakefile(_ context.Context, g *codegen.File, pkg *doc.Package, mk M
 		" ",
 		filepath.Join(includePath, buildDir),
 	)
+	g.P()
+	g.P(".PHONY: ", helpTarget)
+	g.P(helpTarget, ":")
+	for _, line := range strings.Split(makefileHelp(pkg, mk, mks...), "\n") {
+		g.P("\t@printf '%s\\n' ", toShellQuoted(line))
+	}
+	if mk.Completion {
+		completionPath := filepath.Join(includePath, buildDir, completionDir)
+		g.P()
+		g.P(".PHONY: sage-completion")
+		g.P("sage-completion:")
+		g.P("\t@test -f ", filepath.Join(completionPath, "sage.bash"), " || $(MAKE) $(sagefile) >&2")
+		g.P(
+			"\t@case \"$$(basename \"$${SHELL:-bash}\")\" in zsh) cat ", filepath.Join(completionPath, "sage.zsh"),
+			" ;; fish) cat ", filepath.Join(completionPath, "sage.fish"),
+			" ;; *) cat ", filepath.Join(completionPath, "sage.bash"), " ;; esac",
+		)
+	}
 	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
-		if function.Recv == mk.namespaceName() {
+		if function.Recv != mk.namespaceName() {
+			return
+		}
+		params := targetParams(pkg, function)
+		// Every target has a watch helper, which runs the target again when files change.
+		for _, watch := range []bool{false, true} {
+			target, sagefile := toMakeTarget(getTargetFunctionName(function)), "$(sagefile)"
+			if watch {
+				target, sagefile = toMakeWatchTarget(target), "$(sagefile) --watch"
+			}
 			g.P()
-			g.P(".PHONY: ", toMakeTarget(getTargetFunctionName(function)))
-			g.P(toMakeTarget(getTargetFunctionName(function)), ": $(sagefile)")
-			args := toMakeVars(function.Decl.Type.Params.List[1:])
-			if len(args) > 0 {
-				for _, arg := range args {
-					g.P("ifndef ", arg)
-					g.P("\t $(error missing argument ", arg, `="...")`)
-					g.P("endif")
+			g.P(".PHONY: ", target)
+			g.P(target, ": $(sagefile)")
+			for _, param := range params {
+				// Variadic arguments are optional.
+				if param.variadic {
+					continue
 				}
+				arg := toMakeVar(param)
+				g.P("ifndef ", arg)
+				g.P("\t $(error missing argument ", arg, `="...")`)
+				g.P("endif")
 			}
 			g.P(
-				"\t@$(sagefile) ",
-				toSageFunction(getTargetFunctionName(function), args),
+				"\t@", sagefile, " ",
+				toSageFunction(getTargetFunctionName(function), params),
 			)
 		}
 	})
@@ -141,26 +183,106 @@ func generateMakefile(_ context.Context, g *codegen.File, pkg *doc.Package, mk M
 	return nil
 }
 
-// toMakeVars converts input to make vars.
-func toMakeVars(args []*ast.Field) []string {
-	makeVars := make([]string, 0, len(args))
-	for _, b := range args {
-		for _, name := range b.Names {
-			makeVars = append(makeVars, strcase.ToSnake(name.Name))
+// makeHelpTarget returns the name of the generated help target, which is help unless a target already uses it.
+func makeHelpTarget(pkg *doc.Package, mk Makefile) string {
+	result := "help"
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if function.Recv == mk.namespaceName() && toMakeTarget(getTargetFunctionName(function)) == "help" {
+			result = "sage-help"
 		}
+	})
+	return result
+}
+
+// makefileHelp returns the help text of a Makefile, listing its targets and the targets of the namespaced Makefiles
+// included in it.
+func makefileHelp(pkg *doc.Package, mk Makefile, mks ...Makefile) string {
+	var b strings.Builder
+	b.WriteString("Usage: make [target] [variable=value ...]\n")
+	b.WriteString("Run make watch-<target> to run a target again whenever files change.\n")
+	writeTargets := func(namespace string) {
+		tw := tabwriter.NewWriter(&b, 0, 8, 2, ' ', 0)
+		forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+			if function.Recv != namespace {
+				return
+			}
+			_, _ = fmt.Fprintf(tw, "  %s\t%s\n", toMakeTarget(getTargetFunctionName(function)), doc.Synopsis(function.Doc))
+			params := targetParams(pkg, function)
+			if len(params) == 0 {
+				return
+			}
+			vars := make([]string, 0, len(params))
+			for _, param := range params {
+				if param.variadic {
+					vars = append(vars, fmt.Sprintf("[%s=<%s...>]", toMakeVar(param), param.typ))
+					continue
+				}
+				vars = append(vars, fmt.Sprintf("%s=<%s>", toMakeVar(param), param.typ))
+			}
+			_, _ = fmt.Fprintf(tw, "  \t  %s\n", strings.Join(vars, " "))
+		})
+		_ = tw.Flush()
 	}
-	return makeVars
+	b.WriteString("\nTargets:\n")
+	writeTargets(mk.namespaceName())
+	// Add additional makefiles to default makefile
+	if mk.namespaceName() == "" {
+		for _, i := range mks {
+			if i.namespaceName() == "" {
+				continue
+			}
+			mkPath, err := filepath.Rel(FromGitRoot(), i.Path)
+			if err != nil {
+				panic(err)
+			}
+			_, _ = fmt.Fprintf(
+				&b, "\n%s targets, in %s (run with make %s or make -C %s [target]):\n",
+				i.namespaceName(), mkPath, toMakeTarget(i.namespaceName()), filepath.Dir(mkPath),
+			)
+			writeTargets(i.namespaceName())
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// toShellQuoted quotes a string for a shell in a make recipe.
+func toShellQuoted(s string) string {
+	s = strings.ReplaceAll(s, "'", `'\''`)
+	s = strings.ReplaceAll(s, "$", "$$")
+	return "'" + s + "'"
+}
+
+// toMakeVar converts a target parameter to a make var.
+func toMakeVar(param targetParam) string {
+	return strcase.ToSnake(param.name)
 }
 
-// toSageFunction converts input to a sage Target name with the provided args.
-func toSageFunction(target string, args []string) string {
-	for _, arg := range args {
-		arg = fmt.Sprintf("\"$(%s)\"", arg)
+// toSageFunction converts input to a sage Target name with the provided params as make vars.
+// Variadic params are passed unquoted, to split them into separate arguments.
+// The params follow a "--" separator, so that values starting with "-" are not parsed as flags.
+func toSageFunction(target string, params []targetParam) string {
+	if len(params) > 0 {
+		target += " --"
+	}
+	for _, param := range params {
+		arg := fmt.Sprintf("\"$(%s)\"", toMakeVar(param))
+		if param.variadic {
+			arg = fmt.Sprintf("$(%s)", toMakeVar(param))
+		}
 		target += fmt.Sprintf(" %s", arg)
 	}
 	return target
 }
 
+// toMakeWatchTarget returns the make target that watches the provided make target.
+func toMakeWatchTarget(target string) string {
+	return "watch-" + target
+}
+
 // toMakeTarget converts input to make target format.
 func toMakeTarget(str string) string {
 	output := str
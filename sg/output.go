@@ -0,0 +1,125 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// OutputMode controls how the output of the targets run by Deps is written.
+type OutputMode string
+
+const (
+	// OutputStream writes the output of targets as it is produced, which interleaves the output of parallel targets.
+	OutputStream OutputMode = "stream"
+	// OutputGrouped buffers the output of each target run by Deps, and writes it at once when the target completes.
+	// Under GitHub Actions, the output of each target is written in a collapsed log group, except for failed targets.
+	OutputGrouped OutputMode = "grouped"
+)
+
+type outputModeContextKey struct{}
+
+// WithOutputMode attaches an OutputMode to the provided context.
+//
+// Without an OutputMode on the context, the mode is read from the environment variable SAGE_OUTPUT, and defaults to
+// OutputGrouped when running in CI and OutputStream otherwise.
+func WithOutputMode(ctx context.Context, mode OutputMode) context.Context {
+	return context.WithValue(ctx, outputModeContextKey{}, mode)
+}
+
+func getOutputMode(ctx context.Context) OutputMode {
+	if mode, ok := ctx.Value(outputModeContextKey{}).(OutputMode); ok {
+		return mode
+	}
+	if mode, ok := os.LookupEnv("SAGE_OUTPUT"); ok {
+		return OutputMode(mode)
+	}
+	if isTrue(os.Getenv("CI")) {
+		return OutputGrouped
+	}
+	return OutputStream
+}
+
+// outputMu serializes the writing of output groups.
+//
+//nolint:gochecknoglobals
+var outputMu sync.Mutex
+
+type outputGroupContextKey struct{}
+
+// outputGroup buffers the output of a target, in the order it was written.
+type outputGroup struct {
+	mu     sync.Mutex
+	chunks []outputChunk
+}
+
+type outputChunk struct {
+	out io.Writer
+	p   []byte
+}
+
+type outputGroupWriter struct {
+	group *outputGroup
+	out   io.Writer
+}
+
+// Write implements io.Writer.
+func (w outputGroupWriter) Write(p []byte) (int, error) {
+	w.group.mu.Lock()
+	defer w.group.mu.Unlock()
+	w.group.chunks = append(w.group.chunks, outputChunk{out: w.out, p: append([]byte(nil), p...)})
+	return len(p), nil
+}
+
+// groupOutput returns a context that buffers the output of the target with the provided name, when the output is
+// grouped, and a function that writes the buffered output when the target completes.
+func groupOutput(ctx context.Context, name string) (context.Context, func(err error)) {
+	if getOutputMode(ctx) != OutputGrouped {
+		return ctx, func(error) {}
+	}
+	group := &outputGroup{}
+	ctx = context.WithValue(ctx, outputGroupContextKey{}, group)
+	logger := Logger(ctx)
+	ctx = WithLogger(ctx, log.New(outputGroupWriter{group: group, out: logger.Writer()}, logger.Prefix(), logger.Flags()))
+	return ctx, func(err error) {
+		group.flush(name, err)
+	}
+}
+
+// outputWriter returns a writer that writes to out through the output group of the running target, if any.
+func outputWriter(ctx context.Context, out io.Writer) io.Writer {
+	if group, ok := ctx.Value(outputGroupContextKey{}).(*outputGroup); ok {
+		return outputGroupWriter{group: group, out: out}
+	}
+	return out
+}
+
+func (g *outputGroup) flush(name string, err error) {
+	outputMu.Lock()
+	defer outputMu.Unlock()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if len(g.chunks) == 0 {
+		return
+	}
+	if !isGitHubActions() {
+		for _, chunk := range g.chunks {
+			_, _ = chunk.out.Write(chunk.p)
+		}
+		return
+	}
+	// The group markers are written to stderr, and the output to the stream it was written to, so that redirections
+	// of stdout are kept. Failed targets are not grouped, to keep their output expanded.
+	if err == nil {
+		_, _ = fmt.Fprintf(os.Stderr, "::group::%s\n", name)
+	}
+	for _, chunk := range g.chunks {
+		_, _ = chunk.out.Write(chunk.p)
+	}
+	if err == nil {
+		_, _ = fmt.Fprintln(os.Stderr, "::endgroup::")
+	}
+}
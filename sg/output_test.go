@@ -0,0 +1,95 @@
+package sg
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDeps_OutputGrouped(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "false")
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() {
+		os.Stdout = stdout
+	}()
+	ctx := WithOutputMode(context.Background(), OutputGrouped)
+	Deps(ctx, outputGroupedA, outputGroupedB)
+	os.Stdout = stdout
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	output, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 lines but got %q", lines)
+	}
+	for i := 0; i < len(lines); i += 2 {
+		if strings.TrimSuffix(lines[i], "1") != strings.TrimSuffix(lines[i+1], "2") {
+			t.Errorf("expected the output of each target to be grouped but got %q", lines)
+		}
+	}
+}
+
+func TestDeps_OutputGroupedGitHubActions(t *testing.T) {
+	t.Setenv("GITHUB_ACTIONS", "true")
+	stdoutReader, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderrReader, stderrWriter, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = stdoutWriter, stderrWriter
+	defer func() {
+		os.Stdout, os.Stderr = stdout, stderr
+	}()
+	ctx := WithOutputMode(context.Background(), OutputGrouped)
+	Deps(ctx, outputGroupedStreams)
+	os.Stdout, os.Stderr = stdout, stderr
+	if err := stdoutWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := stderrWriter.Close(); err != nil {
+		t.Fatal(err)
+	}
+	output, err := io.ReadAll(stdoutReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errOutput, err := io.ReadAll(stderrReader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(string(output), "] out\n") || strings.Contains(string(output), "::") {
+		t.Errorf("expected stdout to contain the output to stdout, but got %q", output)
+	}
+	lines := strings.Split(strings.TrimSpace(string(errOutput)), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "::group::") ||
+		!strings.HasSuffix(lines[1], "] err") || lines[2] != "::endgroup::" {
+		t.Errorf("expected stderr to contain the group with the output to stderr, but got %q", lines)
+	}
+}
+
+func outputGroupedStreams(ctx context.Context) error {
+	return Command(ctx, "sh", "-c", "echo out; echo err >&2").Run()
+}
+
+func outputGroupedA(ctx context.Context) error {
+	return Command(ctx, "sh", "-c", "echo a1; sleep 0.1; echo a2").Run()
+}
+
+func outputGroupedB(ctx context.Context) error {
+	return Command(ctx, "sh", "-c", "echo b1; sleep 0.1; echo b2").Run()
+}
@@ -0,0 +1,131 @@
+package sg
+
+import (
+	"fmt"
+	"go/ast"
+	"go/doc"
+	"go/token"
+	"go/types"
+	"strconv"
+)
+
+const (
+	stringType      = "string"
+	intType         = "int"
+	boolType        = "bool"
+	float64Type     = "float64"
+	durationType    = "time.Duration"
+	stringSliceType = "[]string"
+)
+
+// targetParam is a custom parameter of a target function, following the context.
+type targetParam struct {
+	// name of the parameter.
+	name string
+	// typ is the Go type of the parameter, or of the elements of a variadic parameter.
+	typ string
+	// variadic is true for a variadic ...string parameter.
+	variadic bool
+	// named is true when typ is a named string type declared in the sagefiles.
+	named bool
+	// values are the allowed values of a named string type, from the constants declared with the type.
+	values []string
+}
+
+// checkTargetParams returns an error for the first target function with a parameter of an unsupported type.
+func checkTargetParams(pkg *doc.Package) error {
+	var err error
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		for _, field := range function.Decl.Type.Params.List[1:] {
+			if _, ok := parseTargetParam(pkg, field); !ok && err == nil {
+				err = fmt.Errorf(
+					"unsupported type %s of parameter in target %s", types.ExprString(field.Type),
+					getTargetFunctionName(function),
+				)
+			}
+		}
+	})
+	return err
+}
+
+// targetParams returns the custom parameters of a target function. The function must have supported parameters.
+func targetParams(pkg *doc.Package, function *doc.Func) []targetParam {
+	var result []targetParam
+	for _, field := range function.Decl.Type.Params.List[1:] {
+		param, ok := parseTargetParam(pkg, field)
+		if !ok {
+			panic(fmt.Errorf("unsupported parameter type in %s", function.Name))
+		}
+		for _, name := range field.Names {
+			param.name = name.Name
+			result = append(result, param)
+		}
+	}
+	return result
+}
+
+// parseTargetParam parses the type of a custom parameter, and reports if the type is supported.
+func parseTargetParam(pkg *doc.Package, field *ast.Field) (targetParam, bool) {
+	switch t := field.Type.(type) {
+	case *ast.Ident:
+		switch t.Name {
+		case stringType, intType, boolType, float64Type:
+			return targetParam{typ: t.Name}, true
+		}
+		for _, namedType := range pkg.Types {
+			if namedType.Name != t.Name || !isStringType(namedType) {
+				continue
+			}
+			return targetParam{typ: t.Name, named: true, values: constValues(namedType)}, true
+		}
+	case *ast.SelectorExpr:
+		if pkgIdent, ok := t.X.(*ast.Ident); ok && pkgIdent.Name+"."+t.Sel.Name == durationType {
+			return targetParam{typ: durationType}, true
+		}
+	case *ast.ArrayType:
+		if elem, ok := t.Elt.(*ast.Ident); ok && t.Len == nil && elem.Name == stringType {
+			return targetParam{typ: stringSliceType}, true
+		}
+	case *ast.Ellipsis:
+		if elem, ok := t.Elt.(*ast.Ident); ok && elem.Name == stringType {
+			return targetParam{typ: stringType, variadic: true}, true
+		}
+	}
+	return targetParam{}, false
+}
+
+// isStringType reports if t is declared with string as its underlying type.
+func isStringType(t *doc.Type) bool {
+	if len(t.Decl.Specs) != 1 {
+		return false
+	}
+	typeSpec, ok := t.Decl.Specs[0].(*ast.TypeSpec)
+	if !ok {
+		return false
+	}
+	ident, ok := typeSpec.Type.(*ast.Ident)
+	return ok && ident.Name == stringType
+}
+
+// constValues returns the values of the string constants declared with t.
+func constValues(t *doc.Type) []string {
+	var result []string
+	for _, constDecl := range t.Consts {
+		for _, spec := range constDecl.Decl.Specs {
+			valueSpec, ok := spec.(*ast.ValueSpec)
+			if !ok {
+				continue
+			}
+			for _, value := range valueSpec.Values {
+				lit, ok := value.(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				if s, err := strconv.Unquote(lit.Value); err == nil {
+					result = append(result, s)
+				}
+			}
+		}
+	}
+	return result
+}
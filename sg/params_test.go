@@ -0,0 +1,80 @@
+package sg
+
+import (
+	"go/ast"
+	"go/doc"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"testing"
+)
+
+func TestTargetParams(t *testing.T) {
+	pkg := parseTestPackage(t, `package main
+
+import (
+	"context"
+	"time"
+)
+
+type env string
+
+const (
+	envDev  env = "dev"
+	envProd env = "prod"
+)
+
+func Deploy(
+	ctx context.Context, e env, timeout time.Duration, dryRun bool, regions []string, services ...string,
+) error {
+	return nil
+}
+`)
+	if err := checkTargetParams(pkg); err != nil {
+		t.Fatal(err)
+	}
+	var actual []targetParam
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		actual = append(actual, targetParams(pkg, function)...)
+	})
+	expected := []targetParam{
+		{name: "e", typ: "env", named: true, values: []string{"dev", "prod"}},
+		{name: "timeout", typ: durationType},
+		{name: "dryRun", typ: boolType},
+		{name: "regions", typ: stringSliceType},
+		{name: "services", typ: stringType, variadic: true},
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %+v but got %+v", expected, actual)
+	}
+}
+
+func TestCheckTargetParams_unsupported(t *testing.T) {
+	pkg := parseTestPackage(t, `package main
+
+import "context"
+
+type Ops sg.Namespace
+
+func (Ops) Scale(ctx context.Context, replicas uint) error { return nil }
+`)
+	const expected = "unsupported type uint of parameter in target Ops:Scale"
+	if err := checkTargetParams(pkg); err == nil || err.Error() != expected {
+		t.Errorf("expected error %q but got %v", expected, err)
+	}
+}
+
+// parseTestPackage parses the source of a sagefile package, with all declarations as when generating files.
+func parseTestPackage(t *testing.T, src string) *doc.Package {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "main.go", src, parser.ParseComments)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkg, err := doc.NewFromFiles(fset, []*ast.File{file}, "./", doc.AllDecls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pkg
+}
@@ -0,0 +1,117 @@
+//go:build !windows
+
+package sg
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// setProcessGroup makes the command start in a new process group, so that it can be signaled with all its children.
+//
+// When stdin is a terminal and this process is in its foreground, the new process group is made the foreground
+// process group of the terminal, since processes in other groups are stopped when they read from the terminal. The
+// terminal then interrupts the group on Ctrl-C, and the foreground is taken back when the group has exited.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	if isTerminal(os.Stdin) && isForeground(os.Stdin) {
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = int(os.Stdin.Fd())
+		watchTerminalForeground()
+	}
+}
+
+// isTerminal reports if f is a terminal, which is a character device other than the null device.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
+	}
+	devNull, err := os.Stat(os.DevNull)
+	return err == nil && !os.SameFile(info, devNull)
+}
+
+// isForeground reports if the process group of this process is the foreground process group of the terminal f.
+func isForeground(f *os.File) bool {
+	pgid, err := foregroundProcessGroup(f)
+	return err == nil && pgid == syscall.Getpgrp()
+}
+
+// terminalForegroundOnce starts watchTerminalForeground once.
+//
+//nolint:gochecknoglobals
+var terminalForegroundOnce sync.Once
+
+// watchTerminalForeground takes back the foreground of the terminal on stdin whenever the process group it was
+// handed to has exited, so that Ctrl-C interrupts this process again. Exits are detected on SIGCHLD, and for groups
+// that outlive their leader, periodically.
+func watchTerminalForeground() {
+	terminalForegroundOnce.Do(func() {
+		// Taking the foreground from a background process group raises SIGTTOU, which would stop this process.
+		signal.Ignore(syscall.SIGTTOU)
+		exited := make(chan os.Signal, 1)
+		signal.Notify(exited, syscall.SIGCHLD)
+		go func() {
+			ticker := time.NewTicker(time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-exited:
+				case <-ticker.C:
+				}
+				pgid, err := foregroundProcessGroup(os.Stdin)
+				if err != nil || pgid == syscall.Getpgrp() {
+					continue
+				}
+				// Only the foreground of groups that have exited is taken back, not of groups that are still running.
+				if err := syscall.Kill(-pgid, 0); errors.Is(err, syscall.ESRCH) {
+					_ = setForegroundProcessGroup(os.Stdin, syscall.Getpgrp())
+				}
+			}
+		}()
+	})
+}
+
+func foregroundProcessGroup(f *os.File) (int, error) {
+	var pgid int32
+	if _, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgid)),
+	); errno != 0 {
+		return 0, errno
+	}
+	return int(pgid), nil
+}
+
+func setForegroundProcessGroup(f *os.File, pgid int) error {
+	value := int32(pgid)
+	if _, _, errno := syscall.Syscall(
+		syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&value)),
+	); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// interruptProcessGroup sends SIGINT to the process group led by p, and SIGKILL after the grace period.
+//
+// The process group outlives its leader, so the group is signaled by ID even after p has exited, and killed even when
+// p has exited on the interrupt. The ID can't be reused by another group while the group has members.
+func interruptProcessGroup(p *os.Process, gracePeriod time.Duration) error {
+	pgid := p.Pid
+	time.AfterFunc(gracePeriod, func() {
+		_ = syscall.Kill(-pgid, syscall.SIGKILL)
+	})
+	if err := syscall.Kill(-pgid, syscall.SIGINT); err != nil && !errors.Is(err, syscall.ESRCH) {
+		return err
+	}
+	return nil
+}
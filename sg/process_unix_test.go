@@ -0,0 +1,83 @@
+//go:build !windows
+
+package sg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCommand_interruptsProcessGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	// The shell runs sleep in a child process, which is left running when only the shell is killed.
+	cmd := Command(ctx, "sh", "-c", "sleep 30; true")
+	start := time.Now()
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > interruptGracePeriod {
+		t.Errorf("expected the command to be interrupted, but it ran for %v", elapsed)
+	}
+	if err := syscall.Kill(-cmd.Process.Pid, 0); !errors.Is(err, syscall.ESRCH) {
+		t.Errorf("expected the process group to have exited, but got %v", err)
+	}
+}
+
+func TestInterruptProcessGroup_killsAfterLeaderExits(t *testing.T) {
+	// The shell exits on the interrupt, while its child ignores the interrupt and outlives it.
+	cmd := Command(context.Background(), "sh", "-c", "(trap '' INT; exec sleep 30) & echo $!; wait")
+	var stdout lockedBuffer
+	cmd.Stdout = &stdout
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var child int
+	for start := time.Now(); child == 0 && time.Since(start) < 5*time.Second; {
+		time.Sleep(10 * time.Millisecond)
+		child, _ = strconv.Atoi(strings.TrimSpace(stdout.String()))
+	}
+	if err := interruptProcessGroup(cmd.Process, 200*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	_ = cmd.Wait()
+	if isRunning(child) {
+		time.Sleep(time.Second)
+	}
+	if isRunning(child) {
+		t.Errorf("expected the child %d to be killed after the grace period", child)
+	}
+}
+
+// isRunning reports if the process with the ID is running, and not a zombie waiting to be reaped.
+func isRunning(pid int) bool {
+	output, err := exec.Command("ps", "-o", "stat=", "-p", strconv.Itoa(pid)).Output()
+	state := strings.TrimSpace(string(output))
+	return err == nil && state != "" && !strings.HasPrefix(state, "Z")
+}
+
+func TestIsTerminal(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	if isTerminal(devNull) {
+		t.Error("expected the null device not to be a terminal")
+	}
+	file, err := os.Open("process_unix_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if isTerminal(file) {
+		t.Error("expected a file not to be a terminal")
+	}
+}
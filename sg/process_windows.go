@@ -0,0 +1,17 @@
+//go:build windows
+
+package sg
+
+import (
+	"os"
+	"os/exec"
+	"time"
+)
+
+// setProcessGroup is a no-op on Windows.
+func setProcessGroup(*exec.Cmd) {}
+
+// interruptProcessGroup kills p, since Windows can't send an interrupt to other processes.
+func interruptProcessGroup(p *os.Process, _ time.Duration) error {
+	return p.Kill()
+}
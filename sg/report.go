@@ -0,0 +1,273 @@
+package sg
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TargetStatus is the outcome of a target run by Deps.
+type TargetStatus string
+
+const (
+	// TargetPassed is the status of a target that returned no error.
+	TargetPassed TargetStatus = "passed"
+	// TargetFailed is the status of a target that returned an error.
+	TargetFailed TargetStatus = "failed"
+	// TargetPanicked is the status of a target that panicked.
+	TargetPanicked TargetStatus = "panicked"
+	// TargetSkipped is the status of a target that was not run, because it had already run.
+	TargetSkipped TargetStatus = "skipped"
+)
+
+// reportTailSize is the number of bytes of stderr output kept for each target in a Report.
+const reportTailSize = 4096
+
+// Report records the outcome of each target run by Deps.
+//
+// Attach a Report to a context with WithReport. The outcomes can be exported as JUnit XML, which most CI systems can
+// render.
+type Report struct {
+	mu      sync.Mutex
+	start   time.Time
+	results []*reportResult
+}
+
+// NewReport creates a new Report.
+func NewReport() *Report {
+	return &Report{start: time.Now()}
+}
+
+type reportContextKey struct{}
+
+type reportTailContextKey struct{}
+
+// WithReport attaches a Report to the provided context.
+func WithReport(ctx context.Context, report *Report) context.Context {
+	return context.WithValue(ctx, reportContextKey{}, report)
+}
+
+func getReport(ctx context.Context) *Report {
+	report, _ := ctx.Value(reportContextKey{}).(*Report)
+	return report
+}
+
+type reportResult struct {
+	name     string
+	status   TargetStatus
+	duration time.Duration
+	err      error
+	tail     *tailBuffer
+}
+
+// ReportTarget runs the target and records its outcome in the Report attached to ctx, if any.
+//
+// Targets run by Deps are recorded automatically. The sagefile binary runs the target given on the command line with
+// ReportTarget.
+func ReportTarget(ctx context.Context, target Target) error {
+	return reportTarget(ctx, target, target.Run)
+}
+
+// reportTarget runs the target and records its outcome, if a report is attached to ctx.
+func reportTarget(ctx context.Context, target Target, run func(context.Context) error) (err error) {
+	report := getReport(ctx)
+	if report == nil {
+		return run(ctx)
+	}
+	result := &reportResult{name: target.Name(), tail: &tailBuffer{}}
+	ctx = context.WithValue(ctx, reportTailContextKey{}, result.tail)
+	logger := Logger(ctx)
+	ctx = WithLogger(ctx, log.New(io.MultiWriter(logger.Writer(), result.tail), logger.Prefix(), logger.Flags()))
+	start := time.Now()
+	defer func() {
+		result.duration = time.Since(start)
+		result.status = TargetPassed
+		if v := recover(); v != nil {
+			result.status = TargetPanicked
+			result.err = fmt.Errorf("%s", v)
+			report.add(result)
+			panic(v)
+		}
+		if err != nil {
+			result.status = TargetFailed
+			result.err = err
+		}
+		report.add(result)
+	}()
+	return run(ctx)
+}
+
+// reportSkipped records that the target was not run, because it had already run.
+func reportSkipped(ctx context.Context, target Target) {
+	if report := getReport(ctx); report != nil {
+		report.add(&reportResult{name: target.Name(), status: TargetSkipped})
+	}
+}
+
+// reportWriter returns a writer that writes to out and to the stderr tail of the running target, if any.
+func reportWriter(ctx context.Context, out io.Writer) io.Writer {
+	if tail, ok := ctx.Value(reportTailContextKey{}).(*tailBuffer); ok {
+		return io.MultiWriter(out, tail)
+	}
+	return out
+}
+
+func (r *Report) add(result *reportResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results = append(r.results, result)
+}
+
+// tailBuffer keeps the last reportTailSize bytes written to it.
+type tailBuffer struct {
+	mu sync.Mutex
+	b  []byte
+}
+
+// Write implements io.Writer.
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.b = append(t.b, p...)
+	if len(t.b) > reportTailSize {
+		t.b = append(t.b[:0], t.b[len(t.b)-reportTailSize:]...)
+	}
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	if t == nil {
+		return ""
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return string(t.b)
+}
+
+type junitTestSuites struct {
+	XMLName   xml.Name       `xml:"testsuites"`
+	Name      string         `xml:"name,attr"`
+	Tests     int            `xml:"tests,attr"`
+	Failures  int            `xml:"failures,attr"`
+	Errors    int            `xml:"errors,attr"`
+	Skipped   int            `xml:"skipped,attr"`
+	Time      string         `xml:"time,attr"`
+	TestSuite junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the outcome of all targets to w as JUnit XML, with one test case per target.
+// Failed targets are reported as failures, panicked targets as errors, and targets that had already run as skipped.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	r.mu.Lock()
+	suite := junitTestSuite{
+		Name:      "sage",
+		Timestamp: r.start.Format(time.RFC3339),
+		Time:      formatJUnitSeconds(time.Since(r.start)),
+	}
+	for _, result := range r.results {
+		className, name := junitName(result.name)
+		testCase := junitTestCase{
+			Name:      name,
+			ClassName: className,
+			Time:      formatJUnitSeconds(result.duration),
+			SystemErr: result.tail.String(),
+		}
+		switch result.status {
+		case TargetFailed:
+			suite.Failures++
+			testCase.Failure = &junitMessage{Message: result.err.Error(), Text: testCase.SystemErr}
+		case TargetPanicked:
+			suite.Errors++
+			testCase.Error = &junitMessage{Message: result.err.Error(), Type: "panic", Text: testCase.SystemErr}
+		case TargetSkipped:
+			suite.Skipped++
+			testCase.Skipped = &junitMessage{Message: "already run"}
+		case TargetPassed:
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+	suite.Tests = len(suite.TestCases)
+	r.mu.Unlock()
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("write junit report: %w", err)
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{
+		Name:      suite.Name,
+		Tests:     suite.Tests,
+		Failures:  suite.Failures,
+		Errors:    suite.Errors,
+		Skipped:   suite.Skipped,
+		Time:      suite.Time,
+		TestSuite: suite,
+	}); err != nil {
+		return fmt.Errorf("write junit report: %w", err)
+	}
+	if _, err := io.WriteString(w, "\n"); err != nil {
+		return fmt.Errorf("write junit report: %w", err)
+	}
+	return nil
+}
+
+// WriteJUnitFile writes the outcome of all targets to the file at path as JUnit XML.
+func (r *Report) WriteJUnitFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("write junit report: %w", err)
+	}
+	if err := r.WriteJUnit(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// junitName splits a target name into the package and the name of the target, e.g. main and GoTest for main.GoTest.
+func junitName(name string) (string, string) {
+	function, args := name, ""
+	if i := strings.Index(name, "("); i != -1 {
+		function, args = name[:i], name[i:]
+	}
+	if i := strings.LastIndex(function, "."); i != -1 {
+		return function[:i], function[i+1:] + args
+	}
+	return "sage", name
+}
+
+func formatJUnitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
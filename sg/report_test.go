@@ -0,0 +1,95 @@
+package sg
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReport_WriteJUnit(t *testing.T) {
+	report := NewReport()
+	ctx := WithReport(context.Background(), report)
+	if err := DepsE(ctx, reportParent, reportFailing); err == nil {
+		t.Fatal("expected error")
+	}
+	var b bytes.Buffer
+	if err := report.WriteJUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(b.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+	if suites.Tests != 4 || suites.Failures != 1 || suites.Skipped != 1 {
+		t.Fatalf("expected 4 tests, 1 failure and 1 skipped but got %+v", suites)
+	}
+	statuses := map[string]string{}
+	for _, testCase := range suites.TestSuite.TestCases {
+		switch {
+		case testCase.Failure != nil:
+			statuses[testCase.Name] += "failed"
+			if !strings.Contains(testCase.Failure.Text, "about to fail") {
+				t.Errorf("expected stderr tail in failure but got %q", testCase.Failure.Text)
+			}
+		case testCase.Skipped != nil:
+			statuses[testCase.Name] += "skipped"
+		default:
+			statuses[testCase.Name] += "passed"
+		}
+	}
+	expected := map[string]string{
+		"reportParent":  "passed",
+		"reportChild":   "passedskipped",
+		"reportFailing": "failed",
+	}
+	for name, status := range expected {
+		if statuses[name] != status {
+			t.Errorf("expected %s to be %s but got %q", name, status, statuses[name])
+		}
+	}
+}
+
+func TestReportTarget(t *testing.T) {
+	report := NewReport()
+	ctx := WithReport(context.Background(), report)
+	if err := ReportTarget(ctx, Fn(reportFailing)); err == nil {
+		t.Fatal("expected error")
+	}
+	var b bytes.Buffer
+	if err := report.WriteJUnit(&b); err != nil {
+		t.Fatal(err)
+	}
+	var suites junitTestSuites
+	if err := xml.Unmarshal(b.Bytes(), &suites); err != nil {
+		t.Fatal(err)
+	}
+	if suites.Tests != 1 || suites.Failures != 1 || suites.TestSuite.TestCases[0].Name != "reportFailing" {
+		t.Errorf("expected 1 failure of reportFailing but got %+v", suites)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	var tail tailBuffer
+	_, _ = tail.Write(bytes.Repeat([]byte("a"), reportTailSize))
+	_, _ = tail.Write([]byte("end"))
+	if got := tail.String(); len(got) != reportTailSize || !strings.HasSuffix(got, "aend") {
+		t.Errorf("expected the last %d bytes but got %d bytes ending in %q", reportTailSize, len(got), got[len(got)-4:])
+	}
+}
+
+func reportParent(ctx context.Context) error {
+	SerialDeps(ctx, reportChild, reportChild)
+	return nil
+}
+
+func reportChild(_ context.Context) error {
+	return nil
+}
+
+func reportFailing(ctx context.Context) error {
+	Logger(ctx).Println("about to fail")
+	return errors.New("failed")
+}
@@ -0,0 +1,56 @@
+package sg
+
+import (
+	"context"
+	"time"
+)
+
+// WithRetry returns a Target that runs the target up to the provided number of attempts, until it succeeds.
+//
+// The delay before the first retry is backoff, and the delay is doubled for each following retry.
+func WithRetry(target interface{}, attempts int, backoff time.Duration) Target {
+	return WithRetryOn(target, attempts, backoff, func(error) bool { return true })
+}
+
+// WithRetryOn works like WithRetry, except that the target is only retried when retryable returns true for the
+// error of the failed attempt.
+func WithRetryOn(target interface{}, attempts int, backoff time.Duration, retryable func(error) bool) Target {
+	return retryTarget{
+		Target:    checkFunctions(target)[0],
+		attempts:  attempts,
+		backoff:   backoff,
+		retryable: retryable,
+	}
+}
+
+type retryTarget struct {
+	Target
+	attempts  int
+	backoff   time.Duration
+	retryable func(error) bool
+}
+
+// Unwrap returns the wrapped Target.
+func (r retryTarget) Unwrap() Target {
+	return r.Target
+}
+
+// Run implements Target.
+func (r retryTarget) Run(ctx context.Context) error {
+	delay := r.backoff
+	for attempt := 1; ; attempt++ {
+		err := r.Target.Run(ctx)
+		if err == nil || attempt >= r.attempts || ctx.Err() != nil || !r.retryable(err) {
+			return err
+		}
+		Logger(ctx).Printf("attempt %d/%d failed, retrying in %v: %v", attempt, r.attempts, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		delay *= 2
+	}
+}
@@ -0,0 +1,58 @@
+package sg
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithRetry(t *testing.T) {
+	errFlaky := errors.New("flaky")
+	t.Run("succeeds after retries", func(t *testing.T) {
+		var attempts int
+		target := WithRetry(func(_ context.Context) error {
+			attempts++
+			if attempts < 3 {
+				return errFlaky
+			}
+			return nil
+		}, 3, time.Millisecond)
+		if err := target.Run(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if attempts != 3 {
+			t.Errorf("expected 3 attempts but got %d", attempts)
+		}
+	})
+	t.Run("not retryable", func(t *testing.T) {
+		var attempts int
+		target := WithRetryOn(func(_ context.Context) error {
+			attempts++
+			return errFlaky
+		}, 3, time.Millisecond, func(err error) bool {
+			return !errors.Is(err, errFlaky)
+		})
+		if err := target.Run(context.Background()); !errors.Is(err, errFlaky) {
+			t.Fatalf("expected %v but got %v", errFlaky, err)
+		}
+		if attempts != 1 {
+			t.Errorf("expected 1 attempt but got %d", attempts)
+		}
+	})
+	t.Run("stable ID", func(t *testing.T) {
+		if expected, actual := Fn(MyFunc).ID(), WithRetry(MyFunc, 3, time.Second).ID(); expected != actual {
+			t.Errorf("expected %q but got %q", expected, actual)
+		}
+	})
+}
+
+func TestWithTimeout(t *testing.T) {
+	target := WithTimeout(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}, time.Millisecond)
+	if err := target.Run(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded but got %v", err)
+	}
+}
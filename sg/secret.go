@@ -0,0 +1,84 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redactedSecret replaces secret values in output.
+const redactedSecret = "***"
+
+type secretsContextKey struct{}
+
+// maskedSecrets are the secret values that have been masked in the GitHub Actions workflow log.
+//
+//nolint:gochecknoglobals
+var maskedSecrets sync.Map
+
+// ContextWithSecrets returns a context with secret values, which are replaced with *** in the output of commands
+// created with Command and in the output of Logger. The secrets of ctx are kept.
+//
+// Multi-line values are redacted line by line. Under GitHub Actions, the values are also masked in the workflow log.
+func ContextWithSecrets(ctx context.Context, values ...string) context.Context {
+	secrets := append([]string(nil), getSecrets(ctx)...)
+	for _, value := range values {
+		for _, line := range strings.Split(value, "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			secrets = append(secrets, line)
+			maskSecret(line)
+		}
+	}
+	// Longer secrets are redacted first, so that secrets containing other secrets are redacted completely.
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	return context.WithValue(ctx, secretsContextKey{}, secrets)
+}
+
+func getSecrets(ctx context.Context) []string {
+	secrets, _ := ctx.Value(secretsContextKey{}).([]string)
+	return secrets
+}
+
+// maskSecret masks a secret value in the GitHub Actions workflow log, once per process.
+func maskSecret(value string) {
+	if !isGitHubActions() {
+		return
+	}
+	if _, loaded := maskedSecrets.LoadOrStore(value, true); loaded {
+		return
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "::add-mask::%s\n", value)
+}
+
+// redactSecrets replaces all secrets in s with ***.
+func redactSecrets(secrets []string, s string) string {
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, redactedSecret)
+	}
+	return s
+}
+
+// redactWriter replaces secrets in each write with ***.
+//
+// Secrets split across writes are not redacted, which is fine for loggers that write each message at once.
+type redactWriter struct {
+	out     io.Writer
+	secrets []string
+}
+
+// Write implements io.Writer.
+func (w redactWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.out, redactSecrets(w.secrets, string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
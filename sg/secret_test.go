@@ -0,0 +1,98 @@
+package sg
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCommand_Secrets(t *testing.T) {
+	ctx := WithLogger(context.Background(), NewLogger("test"))
+	ctx = ContextWithSecrets(ctx, "secret", "multi\nline\n")
+	// The secret is split across writes, and the last line doesn't end with a newline.
+	cmd := Command(ctx, "sh", "-c", `printf 'a sec'; sleep 0.01; printf 'ret\nmulti line\nsecret'`)
+	var stdout bytes.Buffer
+	cmd.Stdout = newLogWriter(ctx, cmd, &stdout)
+	if err := cmd.Run(); err != nil {
+		t.Fatal(err)
+	}
+	const expected = "[test] a ***\n[test] *** ***\n[test] ***\n"
+	if stdout.String() != expected {
+		t.Errorf("expected\n%s\nbut got\n%s", expected, stdout.String())
+	}
+}
+
+func TestCommand_partialLines(t *testing.T) {
+	ctx := WithLogger(context.Background(), NewLogger("test"))
+	ctx = ContextWithSecrets(ctx, "secret")
+	// The prompt is written at once, and the start of the secret after the idle delay.
+	cmd := Command(ctx, "sh", "-c", `printf 'Password: '; sleep 1; printf 'a sec'; sleep 1`)
+	var stdout lockedBuffer
+	cmd.Stdout = newLogWriter(ctx, cmd, &stdout)
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = cmd.Wait()
+	}()
+	time.Sleep(500 * time.Millisecond)
+	if expected := "[test] Password: \n"; stdout.String() != expected {
+		t.Errorf("expected %q but got %q", expected, stdout.String())
+	}
+	time.Sleep(time.Second)
+	if expected := "[test] Password: \n[test] a sec\n"; stdout.String() != expected {
+		t.Errorf("expected %q but got %q", expected, stdout.String())
+	}
+}
+
+func TestEndsWithSecretPrefix(t *testing.T) {
+	for _, tt := range []struct {
+		output   string
+		expected bool
+	}{
+		{output: "", expected: false},
+		{output: "a s", expected: true},
+		{output: "a secre", expected: true},
+		{output: "a secret", expected: false},
+		{output: "a secret!", expected: false},
+		{output: "Password: ", expected: false},
+	} {
+		if actual := endsWithSecretPrefix([]string{"secret"}, []byte(tt.output)); actual != tt.expected {
+			t.Errorf("expected %v for %q but got %v", tt.expected, tt.output, actual)
+		}
+	}
+}
+
+// lockedBuffer is a bytes.Buffer that can be read while a command writes to it.
+type lockedBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.b.String()
+}
+
+func TestLogger_Secrets(t *testing.T) {
+	var b bytes.Buffer
+	ctx := WithLogger(context.Background(), log.New(&b, "[test] ", 0))
+	ctx = ContextWithSecrets(ctx, "t0ken")
+	ctx = AppendLoggerPrefix(ctx, "[child] ")
+	ctx = ContextWithSecrets(ctx, "passw0rd")
+	Logger(ctx).Printf("token=%s password=%s", "t0ken", "passw0rd")
+	const expected = "[test] [child] token=*** password=***\n"
+	if b.String() != expected {
+		t.Errorf("expected %q but got %q", expected, b.String())
+	}
+}
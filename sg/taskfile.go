@@ -0,0 +1,194 @@
+package sg
+
+import (
+	"context"
+	"fmt"
+	"go/doc"
+	"path/filepath"
+	"strings"
+
+	"go.einride.tech/sage/internal/codegen"
+)
+
+// Taskfile is a Taskfile.yml for the Task task runner, generated from the sagefiles.
+// The fields have the same meaning as for a Makefile.
+type Taskfile struct {
+	Namespace     interface{}
+	Path          string
+	DefaultTarget interface{}
+}
+
+func (t Taskfile) makefile() Makefile {
+	return Makefile{Namespace: t.Namespace, Path: t.Path, DefaultTarget: t.DefaultTarget}
+}
+
+func generateTaskfile(_ context.Context, g *codegen.File, pkg *doc.Package, tf Makefile, tfs ...Makefile) error {
+	includePath, err := filepath.Rel(filepath.Dir(tf.Path), FromSageDir())
+	if err != nil {
+		return err
+	}
+	g.P("# To learn more, see ", includePath, "/main.go and https://github.com/einride/sage.")
+	g.P()
+	g.P("version: '3'")
+	g.P()
+	g.P("silent: true")
+	g.P()
+	g.P("vars:")
+	g.P("  SAGE_GO_VERSION: ", toYAMLQuoted(`{{.SAGE_GO_VERSION | default "`+defaultGoVersion+`"}}`))
+	g.P(
+		"  SAGE_GOROOT: ",
+		toYAMLQuoted("{{.ROOT_DIR}}/"+filepath.Join(includePath, toolsDir, "go")+"/{{.SAGE_GO_VERSION}}/go"),
+	)
+	g.P()
+	g.P("env:")
+	g.P("  SAGEFILE: ", toYAMLQuoted("{{.ROOT_DIR}}/"+filepath.Join(includePath, binDir, sageFileBinary)))
+	g.P("  GOWORK: ", toYAMLQuoted(`{{.GOWORK | default "off"}}`))
+	g.P("  GOROOT:")
+	g.P("    sh: ", toYAMLQuoted(`command -v go >/dev/null && echo "${GOROOT:-}" || echo "{{.SAGE_GOROOT}}"`))
+	g.P("  PATH: ", toYAMLQuoted("{{.PATH}}:{{.SAGE_GOROOT}}/bin"))
+	g.P()
+	g.P("tasks:")
+	hasDefaultTarget := false
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if function.Recv == tf.namespaceName() && toMakeTarget(getTargetFunctionName(function)) == "default" {
+			hasDefaultTarget = true
+		}
+	})
+	if !hasDefaultTarget {
+		g.P("  default:")
+		g.P("    cmds:")
+		if len(tf.defaultTargetName()) != 0 {
+			g.P("      - task: ", toMakeTarget(tf.defaultTargetName()))
+		} else {
+			g.P("      - ", toYAMLQuoted("task --taskfile "+filepath.Base(tf.Path)+" --list"))
+		}
+		g.P()
+	}
+	g.P("  sage:go:")
+	g.P("    internal: true")
+	g.P("    status:")
+	g.P("      - command -v go")
+	g.P("    cmds:")
+	g.P("      - ", toYAMLQuoted("echo installing Go {{.SAGE_GO_VERSION}}..."))
+	g.P("      - ", toYAMLQuoted(`mkdir -p "$(dirname "$GOROOT")"`))
+	g.P(
+		"      - ",
+		toYAMLQuoted(
+			`curl -sSL https://go.dev/dl/go{{.SAGE_GO_VERSION}}.{{OS}}-{{ARCH}}.tar.gz | tar xz -C "$(dirname "$GOROOT")"`,
+		),
+	)
+	g.P("      - ", toYAMLQuoted(`touch "$GOROOT/go.mod"`))
+	g.P()
+	g.P("  sage:sagefile:")
+	g.P("    internal: true")
+	g.P("    run: once")
+	g.P("    deps: ['sage:go']")
+	g.P("    dir: ", toYAMLQuoted(includePath))
+	g.P("    cmds:")
+	g.P("      - go mod tidy && go run .")
+	g.P()
+	g.P("  sage:")
+	g.P("    desc: Build the sagefile and generate the task runner files.")
+	g.P("    cmds:")
+	g.P("      - task: sage:sagefile")
+	g.P()
+	g.P("  update-sage:")
+	g.P("    desc: Update Sage to the latest version.")
+	g.P("    deps: ['sage:go']")
+	g.P("    dir: ", toYAMLQuoted(includePath))
+	g.P("    cmds:")
+	g.P("      - go get -d go.einride.tech/sage@latest && go mod tidy && go run .")
+	g.P()
+	g.P("  clean-sage:")
+	g.P("    desc: Remove the Sage tools and build files.")
+	g.P("    cmds:")
+	g.P(
+		"      - git clean -fdx ",
+		filepath.Join(includePath, toolsDir),
+		" ",
+		filepath.Join(includePath, binDir),
+		" ",
+		filepath.Join(includePath, buildDir),
+	)
+	forEachTargetFunction(pkg, func(function *doc.Func, _ *doc.Type) {
+		if function.Recv != tf.namespaceName() {
+			return
+		}
+		params := targetParams(pkg, function)
+		g.P()
+		g.P("  ", toMakeTarget(getTargetFunctionName(function)), ":")
+		if synopsis := doc.Synopsis(function.Doc); synopsis != "" {
+			g.P("    desc: ", toYAMLQuoted(synopsis))
+		}
+		g.P("    deps: ['sage:sagefile']")
+		var preconditions []string
+		for _, param := range params {
+			// Variadic arguments are optional.
+			if param.variadic {
+				continue
+			}
+			preconditions = append(preconditions, toMakeVar(param))
+		}
+		if len(preconditions) > 0 {
+			g.P("    preconditions:")
+			for _, arg := range preconditions {
+				g.P("      - sh: ", toYAMLQuoted("{{if ."+arg+"}}true{{else}}false{{end}}"))
+				g.P("        msg: ", toYAMLQuoted(fmt.Sprintf(`missing argument %s="..."`, arg)))
+			}
+		}
+		if len(params) > 0 {
+			g.P("    env:")
+			for _, param := range params {
+				g.P("      ", toTaskEnv(param), ": ", toYAMLQuoted("{{."+toMakeVar(param)+"}}"))
+			}
+		}
+		g.P("    cmds:")
+		g.P("      - ", toYAMLQuoted(toTaskSageFunction(getTargetFunctionName(function), params)))
+	})
+	// Add additional taskfiles to default taskfile
+	if tf.namespaceName() == "" {
+		for _, i := range tfs {
+			if i.namespaceName() == "" {
+				continue
+			}
+			tfPath, err := filepath.Rel(filepath.Dir(tf.Path), i.Path)
+			if err != nil {
+				return err
+			}
+			g.P()
+			g.P("  ", toMakeTarget(i.namespaceName()), ":")
+			g.P("    desc: Run the default task of the ", i.namespaceName(), " Taskfile.")
+			g.P("    cmds:")
+			g.P("      - ", toYAMLQuoted("task --taskfile "+tfPath))
+		}
+	}
+	return nil
+}
+
+// toTaskEnv returns the environment variable that passes a target parameter from a task to the sagefile.
+func toTaskEnv(param targetParam) string {
+	return "SAGE_ARG_" + strings.ToUpper(toMakeVar(param))
+}
+
+// toTaskSageFunction converts input to a sage Target name with the provided params as environment variables.
+// Variadic params are passed unquoted, to split them into separate arguments.
+// The params follow a "--" separator, so that values starting with "-" are not parsed as flags.
+func toTaskSageFunction(target string, params []targetParam) string {
+	result := `"$SAGEFILE" ` + target
+	if len(params) > 0 {
+		result += " --"
+	}
+	for _, param := range params {
+		if param.variadic {
+			result += " $" + toTaskEnv(param)
+			continue
+		}
+		result += ` "$` + toTaskEnv(param) + `"`
+	}
+	return result
+}
+
+// toYAMLQuoted quotes a string as a single-quoted YAML scalar.
+func toYAMLQuoted(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
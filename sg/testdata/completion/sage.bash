@@ -0,0 +1,144 @@
+# Code generated by go.einride.tech/sage. DO NOT EDIT.
+# Completion of make targets and sagefile arguments for bash.
+# Load with: source <(make -s sage-completion)
+#
+# The script can be loaded for multiple repositories, and completes the targets of the repository of the current
+# directory, or of the directory given to make with -C. Make in other directories is completed as before.
+
+_sage_make_words_c26848463faf() {
+	case "$1" in
+	'') echo 'help sage update-sage clean-sage sage-completion deploy watch-deploy completion-ops' ;;
+	':deploy') echo 'e= services=' ;;
+	':watch-deploy') echo 'e= services=' ;;
+	'ops') echo 'help sage update-sage clean-sage restart watch-restart' ;;
+	'ops:restart') echo 'service=' ;;
+	'ops:watch-restart') echo 'service=' ;;
+	esac
+}
+
+_sage_sagefile_words_c26848463faf() {
+	case "$1" in
+	'') echo '--help --dry-run --dry-run=dot --dry-run=mermaid --watch --output=stream --output=grouped Deploy deploy CompletionOps:Restart completion-ops:restart' ;;
+	'Deploy' | 'deploy') echo '--e= --services=' ;;
+	'CompletionOps:Restart' | 'completion-ops:restart') echo '--service=' ;;
+	esac
+}
+
+# _sage_lookup sets _sage_id to the ID of the loaded repository with the provided root.
+_sage_lookup() {
+	local i
+	for ((i = 0; i < ${#_sage_roots[@]}; i++)); do
+		if [[ ${_sage_roots[i]} == "$1" ]]; then
+			_sage_id=${_sage_ids[i]}
+			return 0
+		fi
+	done
+	return 1
+}
+
+if ! _sage_lookup '/home/user/repo'; then
+	_sage_roots+=('/home/user/repo')
+	_sage_ids+=(c26848463faf)
+fi
+
+# _sage_line splits the command line up to the cursor on whitespace, since COMP_WORDS is also split on : and =.
+_sage_line() {
+	local line=${COMP_LINE:0:COMP_POINT}
+	read -ra _sage_args <<<"$line"
+	_sage_cur=
+	if [[ -n $line && $line != *[[:space:]] ]]; then
+		_sage_cur=${_sage_args[${#_sage_args[@]} - 1]}
+		unset "_sage_args[${#_sage_args[@]} - 1]"
+	fi
+}
+
+# _sage_reply completes the current word from the provided words.
+_sage_reply() {
+	local broken=${COMP_WORDS[COMP_CWORD]}
+	COMPREPLY=($(compgen -W "$1" -- "$_sage_cur"))
+	# Bash only replaces the part of the word after the last : or =.
+	local prefix=${_sage_cur%"$broken"}
+	if [[ -n $prefix && ${#COMPREPLY[@]} -gt 0 ]]; then
+		COMPREPLY=("${COMPREPLY[@]#"$prefix"}")
+	fi
+	if [[ ${#COMPREPLY[@]} == 1 && ${COMPREPLY[0]} == *= ]] && type compopt &>/dev/null; then
+		compopt -o nospace 2>/dev/null
+	fi
+}
+
+# _sage_make_completion prints the completion function of make, unless it's the completion of this script.
+_sage_make_completion() {
+	local spec
+	spec=$(complete -p make 2>/dev/null)
+	if [[ $spec =~ -F\ ([^ ]+) && ${BASH_REMATCH[1]} != _sage_make ]]; then
+		echo "${BASH_REMATCH[1]}"
+	fi
+}
+
+# _sage_make_fallback completes make outside of loaded repositories, with the completion make had before.
+_sage_make_fallback() {
+	if [[ -z $_sage_make_fallback ]] && declare -F _completion_loader >/dev/null; then
+		# bash-completion loads the completion of make lazily, which replaces the completion of this script.
+		_completion_loader make
+		_sage_make_fallback=$(_sage_make_completion)
+		complete -F _sage_make make
+	fi
+	if [[ -n $_sage_make_fallback ]] && declare -F "$_sage_make_fallback" >/dev/null; then
+		"$_sage_make_fallback" "$@"
+	fi
+}
+
+_sage_make() {
+	local dir=. root prefix words i
+	_sage_line
+	for ((i = 1; i < ${#_sage_args[@]}; i++)); do
+		if [[ ${_sage_args[i]} == -C ]]; then
+			dir=${_sage_args[i + 1]}
+		fi
+	done
+	root=$(git -C "$dir" rev-parse --show-toplevel 2>/dev/null)
+	if [[ -z $root ]] || ! _sage_lookup "$root"; then
+		_sage_make_fallback "$@"
+		return
+	fi
+	if [[ ${_sage_args[${#_sage_args[@]} - 1]} == -C ]]; then
+		COMPREPLY=($(compgen -d -- "$_sage_cur"))
+		return
+	fi
+	# Don't complete the values of variables.
+	if [[ $_sage_cur == *=* ]]; then
+		return
+	fi
+	prefix=$(git -C "$dir" rev-parse --show-prefix)
+	prefix=${prefix%/}
+	words=$("_sage_make_words_$_sage_id" "$prefix")
+	for ((i = 1; i < ${#_sage_args[@]}; i++)); do
+		words="$words $("_sage_make_words_$_sage_id" "$prefix:${_sage_args[i]}")"
+	done
+	_sage_reply "$words"
+}
+
+_sage_make_fallback=${_sage_make_fallback:-$(_sage_make_completion)}
+complete -F _sage_make make
+
+_sage_sagefile() {
+	local root target i
+	root=$(git rev-parse --show-toplevel 2>/dev/null)
+	if [[ -z $root ]] || ! _sage_lookup "$root"; then
+		return
+	fi
+	_sage_line
+	for ((i = 1; i < ${#_sage_args[@]}; i++)); do
+		if [[ ${_sage_args[i]} != --* ]]; then
+			target=${_sage_args[i]}
+			break
+		fi
+	done
+	if [[ -z $target ]]; then
+		_sage_reply "$("_sage_sagefile_words_$_sage_id" '')"
+	elif [[ $_sage_cur != *=* ]]; then
+		_sage_reply "--help $("_sage_sagefile_words_$_sage_id" "$target")"
+	fi
+}
+
+complete -F _sage_sagefile sagefile
@@ -0,0 +1,48 @@
+# Code generated by go.einride.tech/sage. DO NOT EDIT.
+# Completion of make targets and sagefile arguments for fish.
+# Load with: make -s sage-completion | source
+
+function __sage_make_dir
+	set -l dir .
+	set -l tokens (commandline -opc)
+	for i in (seq 2 (count $tokens))
+		if test "$tokens[$i]" = -C; and test $i -lt (count $tokens)
+			set dir $tokens[(math $i + 1)]
+		end
+	end
+	set -l root (git -C $dir rev-parse --show-toplevel 2>/dev/null)
+	test "$root" = '/home/user/repo'; or return 1
+	set -l prefix (git -C $dir rev-parse --show-prefix)
+	test "$prefix" = "$argv[1]/"; or test "$prefix" = "$argv[1]"
+end
+
+complete -c make -f -n '__sage_make_dir' -a 'help' -d 'List the targets of the Makefile.'
+complete -c make -f -n '__sage_make_dir' -a 'sage' -d 'Build the sagefile and generate the Makefiles.'
+complete -c make -f -n '__sage_make_dir' -a 'update-sage' -d 'Update Sage to the latest version.'
+complete -c make -f -n '__sage_make_dir' -a 'clean-sage' -d 'Remove the Sage tools and build files.'
+complete -c make -f -n '__sage_make_dir' -a 'sage-completion' -d 'Print the completion script for the current shell.'
+complete -c make -f -n '__sage_make_dir' -a 'deploy' -d 'Deploy deploys the service, with the user'\''s "quoted" settings.'
+complete -c make -f -n '__sage_make_dir; and __fish_seen_subcommand_from deploy' -a 'e= services='
+complete -c make -f -n '__sage_make_dir' -a 'watch-deploy' -d 'Run deploy again whenever files change.'
+complete -c make -f -n '__sage_make_dir; and __fish_seen_subcommand_from watch-deploy' -a 'e= services='
+complete -c make -f -n '__sage_make_dir' -a 'completion-ops' -d 'Run the default target of the CompletionOps Makefile.'
+complete -c make -f -n '__sage_make_dir ops' -a 'help' -d 'List the targets of the Makefile.'
+complete -c make -f -n '__sage_make_dir ops' -a 'sage' -d 'Build the sagefile and generate the Makefiles.'
+complete -c make -f -n '__sage_make_dir ops' -a 'update-sage' -d 'Update Sage to the latest version.'
+complete -c make -f -n '__sage_make_dir ops' -a 'clean-sage' -d 'Remove the Sage tools and build files.'
+complete -c make -f -n '__sage_make_dir ops' -a 'restart' -d 'Restart restarts a service.'
+complete -c make -f -n '__sage_make_dir ops; and __fish_seen_subcommand_from restart' -a 'service='
+complete -c make -f -n '__sage_make_dir ops' -a 'watch-restart' -d 'Run restart again whenever files change.'
+complete -c make -f -n '__sage_make_dir ops; and __fish_seen_subcommand_from watch-restart' -a 'service='
+
+complete -c sagefile -f -l help -d 'Print usage'
+complete -c sagefile -f -n __fish_use_subcommand -l dry-run -d 'Print the dependency graph of the target'
+complete -c sagefile -f -n __fish_use_subcommand -l watch -d 'Run the target again whenever files change'
+complete -c sagefile -f -n __fish_use_subcommand -l output -xa 'stream grouped' -d 'Output mode'
+complete -c sagefile -f -n __fish_use_subcommand -a 'Deploy' -d 'Deploy deploys the service, with the user'\''s "quoted" settings.'
+complete -c sagefile -f -n __fish_use_subcommand -a 'deploy' -d 'Deploy deploys the service, with the user'\''s "quoted" settings.'
+complete -c sagefile -f -n '__fish_seen_subcommand_from Deploy deploy' -l 'e' -r
+complete -c sagefile -f -n '__fish_seen_subcommand_from Deploy deploy' -l 'services' -r
+complete -c sagefile -f -n __fish_use_subcommand -a 'CompletionOps:Restart' -d 'Restart restarts a service.'
+complete -c sagefile -f -n __fish_use_subcommand -a 'completion-ops:restart' -d 'Restart restarts a service.'
+complete -c sagefile -f -n '__fish_seen_subcommand_from CompletionOps:Restart completion-ops:restart' -l 'service' -r
@@ -0,0 +1,34 @@
+package sg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithTimeout returns a Target that cancels the context of the target after the provided duration.
+func WithTimeout(target interface{}, timeout time.Duration) Target {
+	return timeoutTarget{Target: checkFunctions(target)[0], timeout: timeout}
+}
+
+type timeoutTarget struct {
+	Target
+	timeout time.Duration
+}
+
+// Unwrap returns the wrapped Target.
+func (t timeoutTarget) Unwrap() Target {
+	return t.Target
+}
+
+// Run implements Target.
+func (t timeoutTarget) Run(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	err := t.Target.Run(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("timed out after %v: %w", t.timeout, err)
+	}
+	return err
+}
@@ -0,0 +1,193 @@
+package sg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tracer records a span for each target run by Deps and each process started by Command.
+//
+// Attach a Tracer to a context with WithTracer. The spans can be exported in the Chrome trace event format, which
+// can be viewed in Perfetto or chrome://tracing.
+type Tracer struct {
+	mu     sync.Mutex
+	start  time.Time
+	nextID int
+	spans  []*traceSpan
+}
+
+// NewTracer creates a new Tracer.
+func NewTracer() *Tracer {
+	return &Tracer{start: time.Now()}
+}
+
+type tracerContextKey struct{}
+
+type traceSpanContextKey struct{}
+
+// WithTracer attaches a Tracer to the provided context.
+func WithTracer(ctx context.Context, tracer *Tracer) context.Context {
+	return context.WithValue(ctx, tracerContextKey{}, tracer)
+}
+
+func getTracer(ctx context.Context) *Tracer {
+	tracer, _ := ctx.Value(tracerContextKey{}).(*Tracer)
+	return tracer
+}
+
+type traceSpan struct {
+	tracer   *Tracer
+	track    int
+	category string
+	name     string
+	args     map[string]interface{}
+	start    time.Time
+	duration time.Duration
+	err      error
+}
+
+// startSpan starts a span for the tracer attached to ctx, and returns a context with the span attached.
+// When no tracer is attached to ctx, the returned span is nil.
+func startSpan(ctx context.Context, category, name string, args map[string]interface{}) (context.Context, *traceSpan) {
+	tracer := getTracer(ctx)
+	if tracer == nil {
+		return ctx, nil
+	}
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	tracer.nextID++
+	span := &traceSpan{
+		tracer:   tracer,
+		track:    tracer.nextID,
+		category: category,
+		name:     name,
+		args:     args,
+		start:    time.Now(),
+	}
+	// Processes are drawn on the track of the target that started them.
+	if parent, ok := ctx.Value(traceSpanContextKey{}).(*traceSpan); ok && category == "command" {
+		span.track = parent.track
+	}
+	tracer.spans = append(tracer.spans, span)
+	return context.WithValue(ctx, traceSpanContextKey{}, span), span
+}
+
+// end the span with the provided error. It is safe to call end on a nil span.
+func (s *traceSpan) end(err error) {
+	if s == nil {
+		return
+	}
+	s.tracer.mu.Lock()
+	defer s.tracer.mu.Unlock()
+	s.duration = time.Since(s.start)
+	s.err = err
+}
+
+// traceTarget runs the target and records a span for it, if a tracer is attached to ctx.
+func traceTarget(ctx context.Context, target Target) error {
+	dependencies := getDependencies(ctx)
+	chain := make([]string, 0, len(dependencies))
+	for _, dependency := range dependencies[:len(dependencies)-1] {
+		chain = append(chain, dependency.ID())
+	}
+	ctx, span := startSpan(ctx, "target", target.Name(), map[string]interface{}{
+		"id":     target.ID(),
+		"parent": strings.Join(chain, " > "),
+	})
+	err := target.Run(ctx)
+	span.end(err)
+	return err
+}
+
+// traceWriter records a span for a process started by Command.
+//
+// The standard library copies the output of a process to a non-file writer in a goroutine started by Cmd.Start,
+// using ReadFrom when available, and the copying stops when the process closes its output. The duration of the
+// ReadFrom call is therefore the lifetime of the process.
+type traceWriter struct {
+	io.Writer
+	ctx  context.Context
+	args []string
+}
+
+// ReadFrom implements io.ReaderFrom.
+func (w *traceWriter) ReadFrom(r io.Reader) (int64, error) {
+	_, span := startSpan(w.ctx, "command", strings.Join(w.args, " "), map[string]interface{}{
+		"args": w.args,
+	})
+	n, err := io.Copy(w.Writer, r)
+	span.end(err)
+	return n, err
+}
+
+type chromeTraceEvent struct {
+	Name      string                 `json:"name"`
+	Category  string                 `json:"cat"`
+	Phase     string                 `json:"ph"`
+	Timestamp int64                  `json:"ts"`
+	Duration  int64                  `json:"dur"`
+	PID       int                    `json:"pid"`
+	TID       int                    `json:"tid"`
+	Args      map[string]interface{} `json:"args,omitempty"`
+}
+
+// WriteChromeTrace writes all recorded spans to w in the Chrome trace event JSON format.
+// Spans that have not ended yet are written with the duration up until now.
+func (t *Tracer) WriteChromeTrace(w io.Writer) error {
+	t.mu.Lock()
+	events := make([]chromeTraceEvent, 0, len(t.spans))
+	for _, span := range t.spans {
+		duration := span.duration
+		args := make(map[string]interface{}, len(span.args)+1)
+		for k, v := range span.args {
+			args[k] = v
+		}
+		if duration == 0 {
+			duration = time.Since(span.start)
+			args["unfinished"] = true
+		}
+		if span.err != nil {
+			args["error"] = span.err.Error()
+		}
+		events = append(events, chromeTraceEvent{
+			Name:      span.name,
+			Category:  span.category,
+			Phase:     "X",
+			Timestamp: span.start.Sub(t.start).Microseconds(),
+			Duration:  duration.Microseconds(),
+			PID:       1,
+			TID:       span.track,
+			Args:      args,
+		})
+	}
+	t.mu.Unlock()
+	if err := json.NewEncoder(w).Encode(struct {
+		TraceEvents     []chromeTraceEvent `json:"traceEvents"`
+		DisplayTimeUnit string             `json:"displayTimeUnit"`
+	}{
+		TraceEvents:     events,
+		DisplayTimeUnit: "ms",
+	}); err != nil {
+		return fmt.Errorf("write chrome trace: %w", err)
+	}
+	return nil
+}
+
+// WriteChromeTraceFile writes all recorded spans to the file at path in the Chrome trace event JSON format.
+func (t *Tracer) WriteChromeTraceFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("write chrome trace: %w", err)
+	}
+	if err := t.WriteChromeTrace(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
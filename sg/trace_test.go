@@ -0,0 +1,46 @@
+package sg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+)
+
+func TestTracer_WriteChromeTrace(t *testing.T) {
+	tracer := NewTracer()
+	ctx := WithTracer(context.Background(), tracer)
+	Deps(ctx, traceParent)
+	var b bytes.Buffer
+	if err := tracer.WriteChromeTrace(&b); err != nil {
+		t.Fatal(err)
+	}
+	var trace struct {
+		TraceEvents []chromeTraceEvent `json:"traceEvents"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &trace); err != nil {
+		t.Fatal(err)
+	}
+	if len(trace.TraceEvents) != 2 {
+		t.Fatalf("expected 2 events but got %d", len(trace.TraceEvents))
+	}
+	parent, child := trace.TraceEvents[0], trace.TraceEvents[1]
+	if expected := "go.einride.tech/sage/sg.traceParent"; parent.Name != expected {
+		t.Errorf("expected %q but got %q", expected, parent.Name)
+	}
+	if expected := "go.einride.tech/sage/sg.traceParent(null)"; child.Args["parent"] != expected {
+		t.Errorf("expected parent %q but got %q", expected, child.Args["parent"])
+	}
+	if child.Timestamp < parent.Timestamp || child.Duration > parent.Duration {
+		t.Errorf("expected child span %v to be within parent span %v", child, parent)
+	}
+}
+
+func traceParent(ctx context.Context) error {
+	Deps(ctx, traceChild)
+	return nil
+}
+
+func traceChild(_ context.Context) error {
+	return nil
+}
@@ -0,0 +1,201 @@
+package sg
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"go.einride.tech/sage/sg/internal/runner"
+)
+
+const (
+	// watchInterval is the interval between polls for changed files.
+	watchInterval = 500 * time.Millisecond
+	// watchDebounce is how long files must be unchanged before a changed target is re-run.
+	watchDebounce = 300 * time.Millisecond
+)
+
+// Watch runs the target, and re-runs it whenever files in the git repository change, until ctx is done.
+//
+// The files are polled with git ls-files, so files ignored by git are not watched, and compared by content. Changes
+// made while the target runs are ignored, so that targets that write files in the repository, such as generators and
+// formatters, don't trigger themselves. Once the target has finished and files change, the target runs again when the
+// files have been unchanged for a short while. Dependencies run again on every run, since the state of Deps is reset
+// between runs. Failed dependencies fail the run instead of exiting the process.
+func Watch(ctx context.Context, target interface{}) error {
+	return watch(ctx, checkFunctions(target)[0], listWatchedFiles, watchInterval, watchDebounce)
+}
+
+// watchedFile is the state of a watched file, which is zero for a deleted file.
+type watchedFile struct {
+	size    int64
+	modTime time.Time
+	// hash is the hex-encoded SHA-256 hash of the content of the file.
+	hash string
+}
+
+// watchSnapshot returns the state of the watched files. The hashes of files whose size and modification time are
+// unchanged since the previous snapshot are reused.
+type watchSnapshot func(ctx context.Context, previous map[string]watchedFile) (map[string]watchedFile, error)
+
+func watch(
+	ctx context.Context,
+	target Target,
+	snapshot watchSnapshot,
+	interval time.Duration,
+	debounce time.Duration,
+) error {
+	logger := Logger(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var files map[string]watchedFile
+	for {
+		runner.Reset()
+		run := &watchRun{}
+		var runCtx context.Context
+		runCtx, run.cancel = context.WithCancel(context.WithValue(ctx, watchContextKey{}, run))
+		done := make(chan error, 1)
+		go func() {
+			defer func() {
+				if v := recover(); v != nil {
+					done <- recoveredError(v)
+				}
+			}()
+			done <- target.Run(runCtx)
+		}()
+		select {
+		case <-ctx.Done():
+			run.cancel()
+			<-done
+			return nil
+		case err := <-done:
+			run.cancel()
+			// The errors of failed dependencies have been logged by Deps, and the target has been canceled after them.
+			if err != nil && !run.isFailed() {
+				logger.Println(err)
+			}
+		}
+		// Files written by the run are part of the state that changes are detected from.
+		var err error
+		if files, err = snapshot(ctx, files); err != nil {
+			return err
+		}
+		logger.Println("watching for changes...")
+		var lastChange time.Time
+	PollLoop:
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+				newFiles, err := snapshot(ctx, files)
+				if err != nil {
+					return err
+				}
+				if !equalWatchedFiles(files, newFiles) {
+					files = newFiles
+					lastChange = time.Now()
+					continue
+				}
+				if !lastChange.IsZero() && time.Since(lastChange) >= debounce {
+					break PollLoop
+				}
+			}
+		}
+		logger.Println("files changed, running again...")
+	}
+}
+
+type watchContextKey struct{}
+
+// watchRun is the state of a run of a target by Watch.
+type watchRun struct {
+	cancel context.CancelFunc
+	mu     sync.Mutex
+	failed bool
+}
+
+// fail marks the run as failed and cancels it, and reports if the run hadn't already failed.
+func (r *watchRun) fail() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	first := !r.failed
+	r.failed = true
+	r.cancel()
+	return first
+}
+
+func (r *watchRun) isFailed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.failed
+}
+
+// getWatchRun returns the run of the target by Watch that ctx belongs to, if any.
+func getWatchRun(ctx context.Context) *watchRun {
+	run, _ := ctx.Value(watchContextKey{}).(*watchRun)
+	return run
+}
+
+// listWatchedFiles returns the state of the files in the git repository that are not ignored by git.
+func listWatchedFiles(ctx context.Context, previous map[string]watchedFile) (map[string]watchedFile, error) {
+	cmd := exec.CommandContext(ctx, "git", "ls-files", "--cached", "--others", "--exclude-standard", "-z")
+	cmd.Dir = FromGitRoot()
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("list watched files: %w", err)
+	}
+	result := map[string]watchedFile{}
+	for _, path := range strings.Split(string(output), "\x00") {
+		if path == "" {
+			continue
+		}
+		var file watchedFile
+		if info, err := os.Stat(filepath.Join(cmd.Dir, path)); err == nil && info.Mode().IsRegular() {
+			file = watchedFile{size: info.Size(), modTime: info.ModTime()}
+			if prev, ok := previous[path]; ok && prev.size == file.size && prev.modTime.Equal(file.modTime) {
+				file.hash = prev.hash
+			} else if file.hash, err = hashWatchedFile(filepath.Join(cmd.Dir, path)); err != nil {
+				// The file may have been deleted since it was listed.
+				file = watchedFile{}
+			}
+		}
+		result[path] = file
+	}
+	return result, nil
+}
+
+func hashWatchedFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// equalWatchedFiles reports if the files in a and b have the same content.
+func equalWatchedFiles(a, b map[string]watchedFile) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for path, fileA := range a {
+		fileB, ok := b[path]
+		if !ok || fileA.hash != fileB.hash {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,223 @@
+package sg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	files := newFakeWatchedFiles()
+	var depRuns int32
+	dep := func(context.Context) error {
+		atomic.AddInt32(&depRuns, 1)
+		return nil
+	}
+	runs := make(chan int)
+	var targetRuns int
+	target := func(ctx context.Context) error {
+		Deps(ctx, dep)
+		targetRuns++
+		if targetRuns == 2 {
+			// Changes made during the run, e.g. by a generator, don't trigger another run.
+			files.change("generated.go", "generated")
+		}
+		runs <- targetRuns
+		return nil
+	}
+	ctx, cancel := context.WithCancel(WithLogger(context.Background(), NewLogger("test")))
+	done := make(chan error)
+	go func() {
+		done <- watch(ctx, checkFunctions(target)[0], files.snapshot, time.Millisecond, 10*time.Millisecond)
+	}()
+	if run := <-runs; run != 1 {
+		t.Fatalf("expected run 1 but got %d", run)
+	}
+	files.waitForWatching()
+	files.change("main.go", "changed")
+	if run := <-runs; run != 2 {
+		t.Fatalf("expected run 2 but got %d", run)
+	}
+	files.waitForWatching()
+	select {
+	case run := <-runs:
+		t.Fatalf("expected no run after changes made by the target, but got run %d", run)
+	case <-time.After(100 * time.Millisecond):
+	}
+	// Writing the same content doesn't trigger another run.
+	files.change("main.go", "changed")
+	select {
+	case run := <-runs:
+		t.Fatalf("expected no run after unchanged content, but got run %d", run)
+	case <-time.After(100 * time.Millisecond):
+	}
+	files.change("main.go", "changed again")
+	if run := <-runs; run != 3 {
+		t.Fatalf("expected run 3 but got %d", run)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if depRuns != 3 {
+		t.Errorf("expected dependency to run 3 times but ran %d times", depRuns)
+	}
+}
+
+func TestWatch_failedDeps(t *testing.T) {
+	files := newFakeWatchedFiles()
+	var targetRuns, laterDepRuns int32
+	dep := func(ctx context.Context) error {
+		if atomic.LoadInt32(&targetRuns) == 1 {
+			return errors.New("failed")
+		}
+		return nil
+	}
+	laterDep := func(ctx context.Context) error {
+		atomic.AddInt32(&laterDepRuns, 1)
+		return nil
+	}
+	type result struct {
+		run int32
+		err error
+	}
+	results := make(chan result)
+	target := func(ctx context.Context) error {
+		run := atomic.AddInt32(&targetRuns, 1)
+		// Deps returns instead of exiting the process or panicking, and cancels the run.
+		Deps(ctx, dep)
+		Deps(ctx, laterDep)
+		results <- result{run: run, err: ctx.Err()}
+		return ctx.Err()
+	}
+	ctx, cancel := context.WithCancel(WithLogger(context.Background(), NewLogger("test")))
+	done := make(chan error)
+	go func() {
+		done <- watch(ctx, checkFunctions(target)[0], files.snapshot, time.Millisecond, 10*time.Millisecond)
+	}()
+	if r := <-results; r.run != 1 || !errors.Is(r.err, context.Canceled) {
+		t.Fatalf("expected run 1 to be canceled, but got run %d with %v", r.run, r.err)
+	}
+	if n := atomic.LoadInt32(&laterDepRuns); n != 0 {
+		t.Errorf("expected no dependencies to run after the failure, but %d ran", n)
+	}
+	files.waitForWatching()
+	files.change("main.go", "fixed")
+	if r := <-results; r.run != 2 || r.err != nil {
+		t.Fatalf("expected run 2 to succeed, but got run %d with %v", r.run, r.err)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatch_cancelInFlightDeps(t *testing.T) {
+	files := newFakeWatchedFiles()
+	started := make(chan struct{})
+	dep := func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	target := func(ctx context.Context) error {
+		Deps(ctx, dep)
+		return nil
+	}
+	ctx, cancel := context.WithCancel(WithLogger(context.Background(), NewLogger("test")))
+	done := make(chan error)
+	go func() {
+		done <- watch(ctx, checkFunctions(target)[0], files.snapshot, time.Millisecond, 10*time.Millisecond)
+	}()
+	<-started
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListWatchedFiles(t *testing.T) {
+	repo := withTestRepo(t)
+	file := filepath.Join(repo, "main.go")
+	if err := os.WriteFile(file, []byte("package main\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	files, err := listWatchedFiles(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Touching a file without changing its content is not a change.
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+	touched, err := listWatchedFiles(ctx, files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equalWatchedFiles(files, touched) {
+		t.Error("expected touched file to be unchanged")
+	}
+	// Changing the content without changing the size is a change.
+	if err := os.WriteFile(file, []byte("package test\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	changed, err := listWatchedFiles(ctx, touched)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if equalWatchedFiles(touched, changed) {
+		t.Error("expected changed content to be a change")
+	}
+}
+
+// fakeWatchedFiles are watched files with contents given by the test.
+type fakeWatchedFiles struct {
+	mu        sync.Mutex
+	files     map[string]watchedFile
+	snapshots int
+}
+
+func newFakeWatchedFiles() *fakeWatchedFiles {
+	return &fakeWatchedFiles{files: map[string]watchedFile{"main.go": {hash: "main"}}}
+}
+
+func (f *fakeWatchedFiles) change(path, content string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	files := make(map[string]watchedFile, len(f.files)+1)
+	for path, file := range f.files {
+		files[path] = file
+	}
+	files[path] = watchedFile{hash: content}
+	f.files = files
+}
+
+func (f *fakeWatchedFiles) snapshot(context.Context, map[string]watchedFile) (map[string]watchedFile, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.snapshots++
+	return f.files, nil
+}
+
+// waitForWatching waits until the files are watched again after a run, which starts with a snapshot.
+func (f *fakeWatchedFiles) waitForWatching() {
+	f.mu.Lock()
+	snapshots := f.snapshots
+	f.mu.Unlock()
+	for {
+		time.Sleep(time.Millisecond)
+		f.mu.Lock()
+		watching := f.snapshots > snapshots
+		f.mu.Unlock()
+		if watching {
+			return
+		}
+	}
+}
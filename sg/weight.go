@@ -0,0 +1,96 @@
+package sg
+
+import (
+	"context"
+	"sync"
+
+	"go.einride.tech/sage/sg/internal/runner"
+)
+
+// WithWeight returns a Target that occupies weight units of the global parallelism limit while running.
+//
+// The global parallelism limit is set with the environment variable SAGE_MAX_PARALLEL, and defaults to the number of
+// CPUs. Targets have a weight of 1 by default. Use a higher weight for resource-heavy targets, such as linters or
+// tests with the race detector, to make Deps queue them instead of running them alongside other targets.
+func WithWeight(target interface{}, weight int) Target {
+	return weightedTarget{Target: checkFunctions(target)[0], weight: weight}
+}
+
+type weightedTarget struct {
+	Target
+	weight int
+}
+
+// Weight returns the weight of the Target.
+func (w weightedTarget) Weight() int {
+	return w.weight
+}
+
+// Unwrap returns the wrapped Target.
+func (w weightedTarget) Unwrap() Target {
+	return w.Target
+}
+
+// targetWeight returns the weight of target, or of the first Target it wraps that has a weight.
+func targetWeight(target Target) int {
+	for {
+		if weighted, ok := target.(interface{ Weight() int }); ok {
+			return weighted.Weight()
+		}
+		wrapper, ok := target.(interface{ Unwrap() Target })
+		if !ok {
+			return 1
+		}
+		target = wrapper.Unwrap()
+	}
+}
+
+type schedulerSlotContextKey struct{}
+
+// schedulerSlot is the part of the global parallelism limit held by a running target.
+type schedulerSlot struct {
+	mu     sync.Mutex
+	weight int
+	// waiting is the number of calls to Deps the target is currently waiting for.
+	waiting int
+}
+
+// runScheduled runs the target once its locks are acquired and the global parallelism limit allows it.
+func runScheduled(ctx context.Context, target Target, run func(context.Context) error) error {
+	// Acquire locks first, so that targets waiting for a lock don't hold any parallelism.
+	ctx, releaseLocks, err := acquireLocks(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer releaseLocks()
+	weight := targetWeight(target)
+	if err := runner.Acquire(ctx, weight); err != nil {
+		return err
+	}
+	defer runner.Release(weight)
+	return run(context.WithValue(ctx, schedulerSlotContextKey{}, &schedulerSlot{weight: weight}))
+}
+
+// releaseSlot temporarily releases the slot held by the target running with ctx, while it waits for its
+// dependencies. This keeps nested calls to Deps from deadlocking. The returned function reacquires the slot.
+func releaseSlot(ctx context.Context) func() {
+	slot, ok := ctx.Value(schedulerSlotContextKey{}).(*schedulerSlot)
+	if !ok {
+		return func() {}
+	}
+	slot.mu.Lock()
+	slot.waiting++
+	if slot.waiting == 1 {
+		runner.Release(slot.weight)
+	}
+	slot.mu.Unlock()
+	return func() {
+		slot.mu.Lock()
+		defer slot.mu.Unlock()
+		slot.waiting--
+		if slot.waiting == 0 {
+			// The slot is always reacquired, since the target still holds it until it returns.
+			_ = runner.Acquire(context.Background(), slot.weight)
+		}
+	}
+}
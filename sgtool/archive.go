@@ -0,0 +1,76 @@
+package sgtool
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	// tarMagicOffset is the offset of the magic bytes in the header of a tar archive.
+	tarMagicOffset = 257
+	// archiveHeaderSize is the number of bytes needed to detect the archive type of a file.
+	archiveHeaderSize = 512
+)
+
+//nolint:gochecknoglobals
+var (
+	zipMagic   = []byte("PK\x03\x04")
+	gzipMagic  = []byte{0x1F, 0x8B}
+	xzMagic    = []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}
+	bzip2Magic = []byte("BZh")
+	zstdMagic  = []byte{0x28, 0xB5, 0x2F, 0xFD}
+	tarMagic   = []byte("ustar")
+)
+
+// archiveSuffixes are the file name suffixes of the archive types. Longer suffixes come before their suffixes.
+//
+//nolint:gochecknoglobals
+var archiveSuffixes = []struct {
+	suffix      string
+	archiveType archiveType
+}{
+	{suffix: ".tar.gz", archiveType: TarGz},
+	{suffix: ".tgz", archiveType: TarGz},
+	{suffix: ".tar.xz", archiveType: TarXz},
+	{suffix: ".txz", archiveType: TarXz},
+	{suffix: ".tar.bz2", archiveType: TarBz2},
+	{suffix: ".tbz2", archiveType: TarBz2},
+	{suffix: ".tar.zst", archiveType: TarZst},
+	{suffix: ".tzst", archiveType: TarZst},
+	{suffix: ".tar", archiveType: Tar},
+	{suffix: ".zip", archiveType: Zip},
+	{suffix: ".gz", archiveType: Gz},
+}
+
+// detectArchiveType detects the archive type of a file from its first bytes, or else from the suffix of its name.
+//
+// Compressed files are assumed to be tar archives, except gzip-compressed files with a .gz suffix that is not
+// .tar.gz.
+func detectArchiveType(header []byte, filename string) archiveType {
+	fromSuffix := None
+	for _, s := range archiveSuffixes {
+		if strings.HasSuffix(strings.ToLower(filename), s.suffix) {
+			fromSuffix = s.archiveType
+			break
+		}
+	}
+	switch {
+	case bytes.HasPrefix(header, zipMagic):
+		return Zip
+	case bytes.HasPrefix(header, gzipMagic):
+		if fromSuffix == Gz {
+			return Gz
+		}
+		return TarGz
+	case bytes.HasPrefix(header, xzMagic):
+		return TarXz
+	case bytes.HasPrefix(header, bzip2Magic):
+		return TarBz2
+	case bytes.HasPrefix(header, zstdMagic):
+		return TarZst
+	case len(header) >= tarMagicOffset+len(tarMagic) &&
+		bytes.Equal(header[tarMagicOffset:tarMagicOffset+len(tarMagic)], tarMagic):
+		return Tar
+	}
+	return fromSuffix
+}
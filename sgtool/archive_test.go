@@ -0,0 +1,123 @@
+package sgtool
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testTool = "#!/bin/sh\necho tool\n"
+
+func TestDetectArchiveType(t *testing.T) {
+	tarHeader := make([]byte, archiveHeaderSize)
+	copy(tarHeader[tarMagicOffset:], "ustar")
+	for _, tt := range []struct {
+		name     string
+		header   []byte
+		filename string
+		expected archiveType
+	}{
+		{name: "zip", header: []byte("PK\x03\x04..."), filename: "tool", expected: Zip},
+		{name: "tar.gz", header: []byte{0x1F, 0x8B, 0x08}, filename: "tool.tar.gz", expected: TarGz},
+		{name: "gzip without suffix", header: []byte{0x1F, 0x8B, 0x08}, filename: "tool", expected: TarGz},
+		{name: "gz", header: []byte{0x1F, 0x8B, 0x08}, filename: "tool.gz", expected: Gz},
+		{name: "tar.xz", header: readArchiveHeader(t, "tool.tar.xz"), filename: "tool", expected: TarXz},
+		{name: "tar.bz2", header: readArchiveHeader(t, "tool.tar.bz2"), filename: "tool", expected: TarBz2},
+		{name: "tar.zst", header: readArchiveHeader(t, "tool.tar.zst"), filename: "tool", expected: TarZst},
+		{name: "tar", header: tarHeader, filename: "tool", expected: Tar},
+		{name: "suffix", header: []byte("short"), filename: "tool.TBZ2", expected: TarBz2},
+		{name: "content before suffix", header: readArchiveHeader(t, "tool.tar.zst"), filename: "tool.zip", expected: TarZst},
+		{name: "unknown", header: []byte("\x7FELF"), filename: "tool", expected: None},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := detectArchiveType(tt.header, tt.filename); actual != tt.expected {
+				t.Errorf("expected archive type %d but got %d", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHandleFileStream(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		opt      Opt
+		filename string
+		expected string
+	}{
+		{name: "auto tar.xz", opt: WithAutoExtract(), filename: "tool.tar.xz", expected: "bin/tool"},
+		{name: "auto tar.bz2", opt: WithAutoExtract(), filename: "tool.tar.bz2", expected: "bin/tool"},
+		{name: "auto tar.zst", opt: WithAutoExtract(), filename: "tool.tar.zst", expected: "bin/tool"},
+		{name: "auto gz", opt: WithAutoExtract(), filename: "tool.gz", expected: "tool"},
+		{name: "tar.xz", opt: WithUntarXz(), filename: "tool.tar.xz", expected: "bin/tool"},
+		{name: "tar.bz2", opt: WithUntarBz2(), filename: "tool.tar.bz2", expected: "bin/tool"},
+		{name: "tar.zst", opt: WithUntarZst(), filename: "tool.tar.zst", expected: "bin/tool"},
+		{name: "gz", opt: WithGunzip(), filename: "tool.gz", expected: "tool"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFileState()
+			tt.opt(s)
+			dir := t.TempDir()
+			f, err := os.Open(filepath.Join("testdata", tt.filename))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer f.Close()
+			if err := s.handleFileStream(f, dir, tt.filename); err != nil {
+				t.Fatal(err)
+			}
+			expectTestFile(t, filepath.Join(dir, filepath.FromSlash(tt.expected)), testTool)
+		})
+	}
+}
+
+func TestHandleFileStream_unknownFormat(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		opt           Opt
+		filename      string
+		expectedError string
+	}{
+		{name: "auto", opt: WithAutoExtract(), filename: "tool.tar.xz", expectedError: "unable to setup xz stream"},
+		{name: "tar.xz", opt: WithUntarXz(), filename: "tool", expectedError: "unable to setup xz stream"},
+		{name: "tar.bz2", opt: WithUntarBz2(), filename: "tool", expectedError: "unable to untarBz2 the file"},
+		{name: "tar.zst", opt: WithUntarZst(), filename: "tool", expectedError: "unable to untarZst the file"},
+		{name: "gz", opt: WithGunzip(), filename: "tool.gz", expectedError: "unable to setup gzip stream"},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := newFileState()
+			tt.opt(s)
+			input := bytes.NewReader(bytes.Repeat([]byte("not an archive\n"), 64))
+			err := s.handleFileStream(input, t.TempDir(), tt.filename)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedError) {
+				t.Errorf("expected error %q but got %v", tt.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestHandleFileStream_autoNotAnArchive(t *testing.T) {
+	s := newFileState()
+	WithAutoExtract()(s)
+	dir := t.TempDir()
+	if err := s.handleFileStream(strings.NewReader(testTool), dir, "tool"); err != nil {
+		t.Fatal(err)
+	}
+	expectTestFile(t, filepath.Join(dir, "tool"), testTool)
+}
+
+func readArchiveHeader(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) > archiveHeaderSize {
+		data = data[:archiveHeaderSize]
+	}
+	return data
+}
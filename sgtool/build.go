@@ -5,6 +5,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 
@@ -13,19 +14,28 @@ import (
 
 func GoInstall(ctx context.Context, pkg, version string) (string, error) {
 	executable := sg.FromToolsDir("go", pkg, version, filepath.Base(pkg))
-	// Check if executable already exist
-	if _, err := os.Stat(executable); err == nil {
-		symlink, err := CreateSymlink(executable)
-		if err != nil {
-			return "", err
-		}
-		return symlink, nil
+	if isDryRun(ctx, pkg+"@"+version) {
+		return filepath.Join(sg.FromBinDir(), filepath.Base(executable)), nil
+	}
+	if err := linkToolsCache(filepath.Dir(executable)); err != nil {
+		return "", err
+	}
+	if symlink, ok, err := symlinkIfInstalled(executable); err != nil || ok {
+		return symlink, err
+	}
+	unlock, err := lockDir(ctx, filepath.Dir(executable))
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	// The executable may have been installed by another process while waiting for the lock.
+	if symlink, ok, err := symlinkIfInstalled(executable); err != nil || ok {
+		return symlink, err
 	}
 	pkgVersion := fmt.Sprintf("%s@%s", pkg, version)
 	sg.Logger(ctx).Printf("building %s...", pkgVersion)
 	cmd := sg.Command(ctx, "go", "install", pkgVersion)
-	cmd.Env = append(cmd.Env, "GOBIN="+filepath.Dir(executable))
-	if err := cmd.Run(); err != nil {
+	if err := goInstall(cmd, executable); err != nil {
 		return "", err
 	}
 	symlink, err := CreateSymlink(executable)
@@ -38,6 +48,9 @@ func GoInstall(ctx context.Context, pkg, version string) (string, error) {
 // GoInstallWithModfile builds and installs a go binary given the package and a path
 // to the local go.mod file.
 func GoInstallWithModfile(ctx context.Context, pkg, file string) (string, error) {
+	if isDryRun(ctx, pkg) {
+		return filepath.Join(sg.FromBinDir(), filepath.Base(pkg)), nil
+	}
 	cmd := sg.Command(ctx, "go", "list", "-f", "{{.Module.Version}}", pkg)
 	cmd.Dir = filepath.Dir(file)
 	var b bytes.Buffer
@@ -60,19 +73,25 @@ func GoInstallWithModfile(ctx context.Context, pkg, file string) (string, error)
 	commandName := filepath.Base(strings.TrimSpace(b2.String()))
 
 	executable := sg.FromToolsDir("go", pkg, version, commandName)
-	// Check if executable already exist
-	if _, err := os.Stat(executable); err == nil {
-		symlink, err := CreateSymlink(executable)
-		if err != nil {
-			return "", err
-		}
-		return symlink, nil
+	if err := linkToolsCache(filepath.Dir(executable)); err != nil {
+		return "", err
+	}
+	if symlink, ok, err := symlinkIfInstalled(executable); err != nil || ok {
+		return symlink, err
+	}
+	unlock, err := lockDir(ctx, filepath.Dir(executable))
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	// The executable may have been installed by another process while waiting for the lock.
+	if symlink, ok, err := symlinkIfInstalled(executable); err != nil || ok {
+		return symlink, err
 	}
 	sg.Logger(ctx).Printf("building %s...", pkg)
 	cmd = sg.Command(ctx, "go", "install", pkg+"@"+version)
 	cmd.Dir = filepath.Dir(file)
-	cmd.Env = append(cmd.Env, "GOBIN="+filepath.Dir(executable))
-	if err := cmd.Run(); err != nil {
+	if err := goInstall(cmd, executable); err != nil {
 		return "", err
 	}
 	symlink, err := CreateSymlink(executable)
@@ -81,3 +100,34 @@ func GoInstallWithModfile(ctx context.Context, pkg, file string) (string, error)
 	}
 	return symlink, nil
 }
+
+// symlinkIfInstalled creates the symlink of executable in the bin dir, and reports if it's installed.
+func symlinkIfInstalled(executable string) (string, bool, error) {
+	if _, err := os.Stat(executable); err != nil {
+		return "", false, nil
+	}
+	symlink, err := CreateSymlink(executable)
+	if err != nil {
+		return "", false, err
+	}
+	return symlink, true, nil
+}
+
+// goInstall runs cmd, a go install command, with GOBIN set to a temporary directory, and renames the built executable
+// into place. Nothing is installed when cmd fails or is interrupted.
+func goInstall(cmd *exec.Cmd, executable string) error {
+	dir, err := resolveDir(filepath.Dir(executable))
+	if err != nil {
+		return err
+	}
+	tmpDir, err := installTempDir(dir)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	cmd.Env = append(cmd.Env, "GOBIN="+tmpDir)
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	return renameInto(tmpDir, dir, "")
+}
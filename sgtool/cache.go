@@ -0,0 +1,81 @@
+package sgtool
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"go.einride.tech/sage/sg"
+)
+
+// toolsCacheDir returns the directory of the tools cache shared by all repositories, and reports if it's enabled.
+//
+// The shared tools cache is opt-in. Setting SAGE_TOOLS_CACHE to true uses $XDG_CACHE_HOME/sage/tools, or
+// sage/tools in the user cache directory when XDG_CACHE_HOME is not set, and setting it to the absolute path of a
+// directory uses that directory.
+func toolsCacheDir() (string, bool) {
+	value := os.Getenv("SAGE_TOOLS_CACHE")
+	if filepath.IsAbs(value) {
+		return value, true
+	}
+	if enabled, err := strconv.ParseBool(value); err != nil || !enabled {
+		return "", false
+	}
+	if xdgCacheHome := os.Getenv("XDG_CACHE_HOME"); filepath.IsAbs(xdgCacheHome) {
+		return filepath.Join(xdgCacheHome, "sage", "tools"), true
+	}
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(userCacheDir, "sage", "tools"), true
+}
+
+// linkToolsCache makes dir, a directory in the tools dir of the repository, a symlink to its directory in the shared
+// tools cache, when the cache is enabled. The directory in the cache is keyed by the path of dir relative to the
+// tools dir, which contains the name and version of the tool, and by the OS and architecture.
+//
+// Existing directories are kept, so that tools installed before the cache was enabled are still used. The cache is
+// never cleaned up by sage: clean-sage only removes the symlinks of the repository, and directories removed from the
+// cache are created again when linked, for the tools to be installed again.
+func linkToolsCache(dir string) error {
+	cacheDir, ok := toolsCacheDir()
+	if !ok {
+		return nil
+	}
+	rel, err := filepath.Rel(sg.FromToolsDir(), dir)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return nil
+	}
+	target := filepath.Join(cacheDir, rel, runtime.GOOS+"-"+runtime.GOARCH)
+	info, err := os.Lstat(dir)
+	switch {
+	case err == nil && info.Mode()&os.ModeSymlink == 0:
+		return nil
+	case err == nil:
+		if current, err := os.Readlink(dir); err == nil && current == target {
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return fmt.Errorf("link %s to tools cache: %w", dir, err)
+			}
+			return nil
+		}
+		if err := os.Remove(dir); err != nil {
+			return fmt.Errorf("link %s to tools cache: %w", dir, err)
+		}
+	case !os.IsNotExist(err):
+		return fmt.Errorf("link %s to tools cache: %w", dir, err)
+	}
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return fmt.Errorf("link %s to tools cache: %w", dir, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return fmt.Errorf("link %s to tools cache: %w", dir, err)
+	}
+	if err := os.Symlink(target, dir); err != nil {
+		return fmt.Errorf("link %s to tools cache: %w", dir, err)
+	}
+	return nil
+}
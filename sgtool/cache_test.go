@@ -0,0 +1,186 @@
+package sgtool
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"go.einride.tech/sage/sg"
+)
+
+func TestToolsCacheDir(t *testing.T) {
+	for _, tt := range []struct {
+		name          string
+		toolsCache    string
+		xdgCacheHome  string
+		expected      string
+		expectedFound bool
+	}{
+		{
+			name:         "default",
+			xdgCacheHome: "/xdg",
+		},
+		{
+			name:          "enabled",
+			toolsCache:    "true",
+			xdgCacheHome:  "/xdg",
+			expected:      "/xdg/sage/tools",
+			expectedFound: true,
+		},
+		{
+			name:          "override",
+			toolsCache:    "/tools",
+			xdgCacheHome:  "/xdg",
+			expected:      "/tools",
+			expectedFound: true,
+		},
+		{
+			name:         "disabled",
+			toolsCache:   "false",
+			xdgCacheHome: "/xdg",
+		},
+		{
+			name:         "relative path",
+			toolsCache:   "tools",
+			xdgCacheHome: "/xdg",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SAGE_TOOLS_CACHE", tt.toolsCache)
+			t.Setenv("XDG_CACHE_HOME", tt.xdgCacheHome)
+			actual, found := toolsCacheDir()
+			if actual != tt.expected || found != tt.expectedFound {
+				t.Errorf("expected (%q, %v) but got (%q, %v)", tt.expected, tt.expectedFound, actual, found)
+			}
+		})
+	}
+}
+
+func TestLinkToolsCache(t *testing.T) {
+	cacheDir := withToolsCache(t)
+	dir := sg.FromToolsDir("tool", "1.0.0")
+	target := filepath.Join(cacheDir, "tool", "1.0.0", runtime.GOOS+"-"+runtime.GOARCH)
+	if err := linkToolsCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	expectLink(t, dir, target)
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		t.Errorf("expected cache directory %s to exist", target)
+	}
+	// Linking again keeps the link.
+	if err := linkToolsCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	expectLink(t, dir, target)
+}
+
+func TestLinkToolsCache_replaceLink(t *testing.T) {
+	cacheDir := withToolsCache(t)
+	dir := sg.FromToolsDir("tool", "1.0.0")
+	target := filepath.Join(cacheDir, "tool", "1.0.0", runtime.GOOS+"-"+runtime.GOARCH)
+	other := t.TempDir()
+	if err := os.Symlink(other, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := linkToolsCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	expectLink(t, dir, target)
+	if _, err := os.Stat(other); err != nil {
+		t.Errorf("expected the previous link target to be kept: %v", err)
+	}
+}
+
+func TestLinkToolsCache_staleLink(t *testing.T) {
+	cacheDir := withToolsCache(t)
+	dir := sg.FromToolsDir("tool", "1.0.0")
+	target := filepath.Join(cacheDir, "tool", "1.0.0", runtime.GOOS+"-"+runtime.GOARCH)
+	if err := os.Symlink(filepath.Join(t.TempDir(), "removed"), dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := linkToolsCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	expectLink(t, dir, target)
+}
+
+func TestLinkToolsCache_removedCache(t *testing.T) {
+	cacheDir := withToolsCache(t)
+	dir := sg.FromToolsDir("tool", "1.0.0")
+	target := filepath.Join(cacheDir, "tool", "1.0.0", runtime.GOOS+"-"+runtime.GOARCH)
+	if err := linkToolsCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.RemoveAll(cacheDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := linkToolsCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	expectLink(t, dir, target)
+	if info, err := os.Stat(target); err != nil || !info.IsDir() {
+		t.Errorf("expected cache directory %s to be created again", target)
+	}
+}
+
+func TestLinkToolsCache_existingDir(t *testing.T) {
+	withToolsCache(t)
+	dir := sg.FromToolsDir("tool", "1.0.0")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := linkToolsCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(dir); err != nil || !info.IsDir() {
+		t.Errorf("expected %s to be kept as a directory", dir)
+	}
+}
+
+func TestLinkToolsCache_disabled(t *testing.T) {
+	withToolsCache(t)
+	t.Setenv("SAGE_TOOLS_CACHE", "")
+	dir := sg.FromToolsDir("tool", "1.0.0")
+	if err := linkToolsCache(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to not exist, but got %v", dir, err)
+	}
+}
+
+// withToolsCache runs the test in a new git repository with a new tools cache, and returns the tools cache.
+func withToolsCache(t *testing.T) string {
+	t.Helper()
+	repo := t.TempDir()
+	if output, err := exec.Command("git", "init", "-q", repo).CombinedOutput(); err != nil {
+		t.Fatalf("git init: %v: %s", err, output)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(repo); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = os.Chdir(wd)
+	})
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	t.Setenv("SAGE_TOOLS_CACHE", cacheDir)
+	return cacheDir
+}
+
+func expectLink(t *testing.T, dir, target string) {
+	t.Helper()
+	actual, err := os.Readlink(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != target {
+		t.Errorf("expected %s to link to %s, but got %s", dir, target, actual)
+	}
+}
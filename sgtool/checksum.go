@@ -0,0 +1,86 @@
+package sgtool
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// WithSHA256 verifies the file against a hex-encoded SHA-256 checksum. Nothing is installed when the checksum
+// doesn't match.
+func WithSHA256(checksum string) Opt {
+	return func(f *fileState) {
+		f.sha256 = strings.ToLower(checksum)
+	}
+}
+
+// WithChecksumFile verifies the file against the SHA-256 checksum of entryName in the checksum file at url, in the
+// format printed by sha256sum, such as the checksums.txt published with many releases. Nothing is installed when the
+// checksum doesn't match, or when the checksum file has no entry for entryName.
+func WithChecksumFile(url, entryName string) Opt {
+	return func(f *fileState) {
+		f.checksumURL = url
+		f.checksumEntry = entryName
+	}
+}
+
+// resolveChecksum downloads the checksum file given by WithChecksumFile, unless a checksum is given by WithSHA256.
+func (s *fileState) resolveChecksum(ctx context.Context) error {
+	if s.sha256 != "" || s.checksumURL == "" {
+		return nil
+	}
+	body, cleanup, err := s.downloadBinary(ctx, s.checksumURL)
+	defer cleanup()
+	if err != nil {
+		return fmt.Errorf("unable to download checksum file: %w", err)
+	}
+	checksum, err := findChecksum(body, s.checksumEntry)
+	if err != nil {
+		return fmt.Errorf("checksum file %s: %w", s.checksumURL, err)
+	}
+	s.sha256 = checksum
+	return nil
+}
+
+// findChecksum returns the checksum of entryName in a checksum file in the format printed by sha256sum.
+func findChecksum(r io.Reader, entryName string) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		// Binary mode entries are prefixed by *.
+		name := strings.TrimPrefix(strings.TrimPrefix(fields[1], "*"), "./")
+		if name == entryName {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("no checksum for %s", entryName)
+}
+
+// extract writes or extracts the file read from r into dir while hashing it, and returns its checksum. An error is
+// returned when the checksum doesn't match the expected checksum.
+func (s *fileState) extract(r io.Reader, dir, source string) (string, error) {
+	hash := sha256.New()
+	if err := s.handleFileStream(io.TeeReader(r, hash), dir, path.Base(source)); err != nil {
+		return "", err
+	}
+	// Extraction may stop before the end of the file, e.g. at the end of a tar archive.
+	if _, err := io.Copy(hash, r); err != nil {
+		return "", fmt.Errorf("unable to read %s: %w", source, err)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if s.sha256 != "" && checksum != s.sha256 {
+		return "", fmt.Errorf("checksum mismatch for %s: expected sha256 %s but got %s", source, s.sha256, checksum)
+	}
+	return checksum, nil
+}
@@ -0,0 +1,103 @@
+package sgtool
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindChecksum(t *testing.T) {
+	const checksums = `# checksums of release v1.0.0
+0000000000000000000000000000000000000000000000000000000000000001  tool_linux_amd64.tar.gz
+0000000000000000000000000000000000000000000000000000000000000002 *tool_darwin_arm64.tar.gz
+0000000000000000000000000000000000000000000000000000000000000003  ./tool_windows_amd64.zip
+00000000000000000000000000000000000000000000000000000000000000AB  tool.txt
+`
+	for _, tt := range []struct {
+		name          string
+		entryName     string
+		expected      string
+		expectedError string
+	}{
+		{
+			name:      "text mode",
+			entryName: "tool_linux_amd64.tar.gz",
+			expected:  "0000000000000000000000000000000000000000000000000000000000000001",
+		},
+		{
+			name:      "binary mode",
+			entryName: "tool_darwin_arm64.tar.gz",
+			expected:  "0000000000000000000000000000000000000000000000000000000000000002",
+		},
+		{
+			name:      "relative path",
+			entryName: "tool_windows_amd64.zip",
+			expected:  "0000000000000000000000000000000000000000000000000000000000000003",
+		},
+		{
+			name:      "upper case",
+			entryName: "tool.txt",
+			expected:  "00000000000000000000000000000000000000000000000000000000000000ab",
+		},
+		{
+			name:          "missing",
+			entryName:     "tool_linux_arm64.tar.gz",
+			expectedError: "no checksum for tool_linux_arm64.tar.gz",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := findChecksum(strings.NewReader(checksums), tt.entryName)
+			if tt.expectedError != "" {
+				if err == nil || err.Error() != tt.expectedError {
+					t.Errorf("expected error %q but got %v", tt.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actual != tt.expected {
+				t.Errorf("expected %s but got %s", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestExtract_checksum(t *testing.T) {
+	const content = "#!/bin/sh\necho tool\n"
+	sum := sha256.Sum256([]byte(content))
+	checksum := hex.EncodeToString(sum[:])
+	t.Run("match", func(t *testing.T) {
+		dir := t.TempDir()
+		s := newFileState()
+		WithSHA256(strings.ToUpper(checksum))(s)
+		actual, err := s.extract(strings.NewReader(content), dir, "https://example.com/tool")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual != checksum {
+			t.Errorf("expected checksum %s but got %s", checksum, actual)
+		}
+		if data, err := os.ReadFile(filepath.Join(dir, "tool")); err != nil || string(data) != content {
+			t.Errorf("expected tool to be written, but got %q, %v", data, err)
+		}
+	})
+	t.Run("mismatch", func(t *testing.T) {
+		dir := t.TempDir()
+		s := newFileState()
+		WithSHA256(strings.Repeat("0", 64))(s)
+		_, err := s.extract(strings.NewReader(content), dir, "https://example.com/tool")
+		if err == nil {
+			t.Fatal("expected checksum mismatch")
+		}
+		expected := "checksum mismatch for https://example.com/tool: expected sha256 " + strings.Repeat("0", 64) +
+			" but got " + checksum
+		if err.Error() != expected {
+			t.Errorf("expected error %q but got %q", expected, err.Error())
+		}
+	})
+}
@@ -0,0 +1,354 @@
+package sgtool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"go.einride.tech/sage/sg"
+)
+
+const (
+	// maxDownloadAttempts is the number of attempts to download a file without making progress before giving up.
+	maxDownloadAttempts = 5
+	// downloadResponseTimeout is the time to wait for the response headers of a download.
+	downloadResponseTimeout = 30 * time.Second
+	// downloadProgressInterval is the interval between updates of the progress line of a download.
+	downloadProgressInterval = 200 * time.Millisecond
+)
+
+// downloadRetryDelay is the delay before the first retry of a download, which doubles with each retry.
+//
+//nolint:gochecknoglobals
+var downloadRetryDelay = time.Second
+
+// downloadIdleTimeout is the time a read of the body of a download can wait for data, before the download is
+// considered stalled.
+//
+//nolint:gochecknoglobals
+var downloadIdleTimeout = 30 * time.Second
+
+// errDownloadStalled is the error of a read of the body of a download that received no data within the idle timeout.
+var errDownloadStalled = errors.New("download stalled")
+
+// downloadClient is the HTTP client of downloads, which uses the proxy given by HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+//
+//nolint:gochecknoglobals
+var downloadClient = newDownloadClient()
+
+func newDownloadClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+	transport.ResponseHeaderTimeout = downloadResponseTimeout
+	return &http.Client{Transport: transport}
+}
+
+// downloadBinary downloads the file at addr, rewritten by SAGE_DOWNLOAD_MIRROR.
+//
+// Requests failing with a 5xx status code or a connection reset are retried with exponential backoff. Downloads
+// interrupted by a connection reset, or stalled without receiving data, are resumed with a Range request.
+func (s *fileState) downloadBinary(ctx context.Context, addr string) (io.ReadCloser, func(), error) {
+	d := &download{ctx: ctx, url: mirrorURL(addr), header: s.httpHeader, size: -1}
+	if err := d.connect(); err != nil {
+		return nil, func() {}, fmt.Errorf("download binary %s: %w", addr, err)
+	}
+	d.progress = newDownloadProgress(ctx, path.Base(addr), d.size)
+	return d, func() { d.Close() }, nil
+}
+
+// download is the body of a download, which retries and resumes the download when the connection fails.
+type download struct {
+	ctx    context.Context
+	url    string
+	header http.Header
+	body   io.ReadCloser
+	// offset is the number of bytes read.
+	offset int64
+	// size is the size of the file, or -1 if it's unknown.
+	size int64
+	// validator is the ETag or Last-Modified header of the file, used to resume the download of the same file.
+	validator string
+	// retries is the number of retries since the download last made progress.
+	retries  int
+	progress *downloadProgress
+}
+
+// Read implements io.Reader.
+func (d *download) Read(p []byte) (int, error) {
+	for {
+		n, err := d.body.Read(p)
+		d.offset += int64(n)
+		if n > 0 {
+			d.retries = 0
+			d.progress.update(d.offset)
+		}
+		if err == nil || errors.Is(err, io.EOF) || !isRetryableDownloadError(d.ctx, err) {
+			return n, err
+		}
+		_ = d.body.Close()
+		if err := d.backoff(err); err != nil {
+			return n, err
+		}
+		if err := d.connect(); err != nil {
+			return n, err
+		}
+		if n > 0 {
+			return n, nil
+		}
+	}
+}
+
+// Close implements io.Closer.
+func (d *download) Close() error {
+	d.progress.done(d.offset)
+	return d.body.Close()
+}
+
+// connect requests the file from the current offset, and retries the request until it succeeds.
+func (d *download) connect() error {
+	for {
+		retry, err := d.get()
+		if err == nil || !retry {
+			return err
+		}
+		if err := d.backoff(err); err != nil {
+			return err
+		}
+	}
+}
+
+// get requests the file from the current offset, and reports if a failed request can be retried.
+func (d *download) get() (bool, error) {
+	ctx, cancel := context.WithCancel(d.ctx)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		cancel()
+		return false, err
+	}
+	req.Header = d.header.Clone()
+	if d.offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", d.offset))
+		if d.validator != "" {
+			req.Header.Set("If-Range", d.validator)
+		}
+	}
+	//nolint:bodyclose // false positive due to Close
+	resp, err := downloadClient.Do(req)
+	if err != nil {
+		cancel()
+		return isRetryableDownloadError(d.ctx, err), err
+	}
+	body := newIdleTimeoutBody(resp.Body, cancel)
+	switch {
+	case d.offset > 0 && resp.StatusCode == http.StatusPartialContent:
+		if !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", d.offset)) {
+			_ = body.Close()
+			return false, fmt.Errorf("resume: unexpected content range %q", resp.Header.Get("Content-Range"))
+		}
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		if d.offset == 0 {
+			d.size = resp.ContentLength
+			d.validator = responseValidator(resp)
+			break
+		}
+		// The server doesn't support Range requests, so the bytes already read are skipped.
+		if validator := responseValidator(resp); validator != d.validator {
+			_ = body.Close()
+			return false, fmt.Errorf("resume: file changed during download")
+		}
+		if _, err := io.CopyN(io.Discard, body, d.offset); err != nil {
+			_ = body.Close()
+			return isRetryableDownloadError(d.ctx, err), fmt.Errorf("resume: %w", err)
+		}
+	default:
+		_ = body.Close()
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, fmt.Errorf("status code %d", resp.StatusCode)
+	}
+	d.body = body
+	return false, nil
+}
+
+// idleTimeoutBody is the body of a download, whose request is canceled when a read receives no data within the idle
+// timeout. The stall is then returned as errDownloadStalled, which is retried.
+type idleTimeoutBody struct {
+	body    io.ReadCloser
+	cancel  context.CancelFunc
+	timer   *time.Timer
+	stalled int32
+}
+
+func newIdleTimeoutBody(body io.ReadCloser, cancel context.CancelFunc) *idleTimeoutBody {
+	b := &idleTimeoutBody{body: body, cancel: cancel}
+	b.timer = time.AfterFunc(downloadIdleTimeout, func() {
+		atomic.StoreInt32(&b.stalled, 1)
+		cancel()
+	})
+	b.timer.Stop()
+	return b
+}
+
+// Read implements io.Reader.
+func (b *idleTimeoutBody) Read(p []byte) (int, error) {
+	b.timer.Reset(downloadIdleTimeout)
+	n, err := b.body.Read(p)
+	b.timer.Stop()
+	if err != nil && atomic.LoadInt32(&b.stalled) == 1 {
+		err = fmt.Errorf("%w: no data received for %v", errDownloadStalled, downloadIdleTimeout)
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (b *idleTimeoutBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.body.Close()
+}
+
+// backoff waits before the next retry of the download, or returns err when there are no more attempts left.
+func (d *download) backoff(err error) error {
+	d.retries++
+	if d.retries >= maxDownloadAttempts {
+		return err
+	}
+	delay := downloadRetryDelay << (d.retries - 1)
+	d.progress.clear()
+	sg.Logger(d.ctx).Printf("retrying download of %s in %v: %v", d.url, delay, err)
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-d.ctx.Done():
+		return d.ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func responseValidator(resp *http.Response) string {
+	if etag := resp.Header.Get("ETag"); etag != "" {
+		return etag
+	}
+	return resp.Header.Get("Last-Modified")
+}
+
+// isRetryableDownloadError reports if a download failing with err can be retried.
+func isRetryableDownloadError(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, errDownloadStalled) ||
+		errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// mirrorURL rewrites addr according to SAGE_DOWNLOAD_MIRROR.
+//
+// SAGE_DOWNLOAD_MIRROR is either a comma-separated list of prefix=replacement rules, where the rule with the longest
+// matching prefix is applied, or the URL of a proxy to which the host and path of addr are appended. For example,
+// https://github.com/=https://proxy.example.com/github/ rewrites GitHub URLs, while https://proxy.example.com rewrites
+// https://dl.google.com/go.tar.gz to https://proxy.example.com/dl.google.com/go.tar.gz.
+func mirrorURL(addr string) string {
+	mirror := os.Getenv("SAGE_DOWNLOAD_MIRROR")
+	if mirror == "" {
+		return addr
+	}
+	if !strings.Contains(mirror, "=") {
+		u, err := url.Parse(addr)
+		if err != nil || u.Host == "" {
+			return addr
+		}
+		return strings.TrimSuffix(mirror, "/") + "/" + u.Host + u.RequestURI()
+	}
+	var prefix, replacement string
+	for _, rule := range strings.Split(mirror, ",") {
+		from, to, ok := strings.Cut(strings.TrimSpace(rule), "=")
+		if ok && strings.HasPrefix(addr, from) && len(from) > len(prefix) {
+			prefix, replacement = from, to
+		}
+	}
+	if prefix == "" {
+		return addr
+	}
+	return replacement + strings.TrimPrefix(addr, prefix)
+}
+
+// downloadProgress prints the progress of a download on a line of the terminal.
+type downloadProgress struct {
+	prefix  string
+	name    string
+	size    int64
+	last    time.Time
+	printed bool
+}
+
+// newDownloadProgress returns the progress of a download, or nil when stderr is not a terminal.
+func newDownloadProgress(ctx context.Context, name string, size int64) *downloadProgress {
+	info, err := os.Stderr.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return nil
+	}
+	return &downloadProgress{prefix: sg.Logger(ctx).Prefix(), name: name, size: size, last: time.Now()}
+}
+
+func (p *downloadProgress) update(n int64) {
+	if p == nil || time.Since(p.last) < downloadProgressInterval {
+		return
+	}
+	p.last = time.Now()
+	p.print(n)
+}
+
+func (p *downloadProgress) print(n int64) {
+	line := fmt.Sprintf("%sfetching %s: %s", p.prefix, p.name, formatBytes(n))
+	if p.size > 0 {
+		line += fmt.Sprintf(" / %s (%d%%)", formatBytes(p.size), n*100/p.size)
+	}
+	// Clear the rest of the line, in case the previous line was longer.
+	_, _ = fmt.Fprintf(os.Stderr, "\r%s\x1b[K", line)
+	p.printed = true
+}
+
+// clear clears the progress line, so that it can be replaced by a log line.
+func (p *downloadProgress) clear() {
+	if p == nil || !p.printed {
+		return
+	}
+	_, _ = fmt.Fprint(os.Stderr, "\r\x1b[K")
+	p.printed = false
+}
+
+// done prints the final progress of the download, if any progress has been printed.
+func (p *downloadProgress) done(n int64) {
+	if p == nil || !p.printed {
+		return
+	}
+	p.print(n)
+	_, _ = fmt.Fprintln(os.Stderr)
+	p.printed = false
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,290 @@
+package sgtool
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMirrorURL(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		mirror   string
+		addr     string
+		expected string
+	}{
+		{
+			name:     "no mirror",
+			addr:     "https://github.com/org/tool/releases/download/v1.0.0/tool.tar.gz",
+			expected: "https://github.com/org/tool/releases/download/v1.0.0/tool.tar.gz",
+		},
+		{
+			name:     "proxy",
+			mirror:   "https://proxy.example.com/",
+			addr:     "https://dl.google.com/go/go1.17.tar.gz?a=b",
+			expected: "https://proxy.example.com/dl.google.com/go/go1.17.tar.gz?a=b",
+		},
+		{
+			name:     "rule",
+			mirror:   "https://github.com/=https://proxy.example.com/github/",
+			addr:     "https://github.com/org/tool/releases/download/v1.0.0/tool.tar.gz",
+			expected: "https://proxy.example.com/github/org/tool/releases/download/v1.0.0/tool.tar.gz",
+		},
+		{
+			name:     "longest rule",
+			mirror:   "https://github.com/=https://a.example.com/, https://github.com/org/=https://b.example.com/",
+			addr:     "https://github.com/org/tool.tar.gz",
+			expected: "https://b.example.com/tool.tar.gz",
+		},
+		{
+			name:     "no matching rule",
+			mirror:   "https://github.com/=https://proxy.example.com/github/",
+			addr:     "https://dl.google.com/go/go1.17.tar.gz",
+			expected: "https://dl.google.com/go/go1.17.tar.gz",
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SAGE_DOWNLOAD_MIRROR", tt.mirror)
+			if actual := mirrorURL(tt.addr); actual != tt.expected {
+				t.Errorf("expected %s but got %s", tt.expected, actual)
+			}
+		})
+	}
+}
+
+func TestDownload_retryServerError(t *testing.T) {
+	withDownloadRetryDelay(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = io.WriteString(w, downloadTestContent)
+	}))
+	defer server.Close()
+	expectDownload(t, server.URL)
+	if requests != 3 {
+		t.Errorf("expected 3 requests, but got %d", requests)
+	}
+}
+
+func TestDownload_retryConnectionReset(t *testing.T) {
+	withDownloadRetryDelay(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			resetConnection(t, w, "")
+			return
+		}
+		_, _ = io.WriteString(w, downloadTestContent)
+	}))
+	defer server.Close()
+	expectDownload(t, server.URL)
+	if requests != 2 {
+		t.Errorf("expected 2 requests, but got %d", requests)
+	}
+}
+
+func TestDownload_resume(t *testing.T) {
+	withDownloadRetryDelay(t)
+	const offset = len(downloadTestContent) / 2
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			resetConnection(t, w, downloadTestContent[:offset])
+			return
+		}
+		if expected := fmt.Sprintf("bytes=%d-", offset); r.Header.Get("Range") != expected {
+			t.Errorf("expected Range %q but got %q", expected, r.Header.Get("Range"))
+		}
+		if expected := `"v1"`; r.Header.Get("If-Range") != expected {
+			t.Errorf("expected If-Range %q but got %q", expected, r.Header.Get("If-Range"))
+		}
+		w.Header().Set("ETag", `"v1"`)
+		size := len(downloadTestContent)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, size-1, size))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.WriteString(w, downloadTestContent[offset:])
+	}))
+	defer server.Close()
+	expectDownload(t, server.URL)
+	if requests != 2 {
+		t.Errorf("expected 2 requests, but got %d", requests)
+	}
+}
+
+func TestDownload_resumeStalled(t *testing.T) {
+	withDownloadRetryDelay(t)
+	idleTimeout := downloadIdleTimeout
+	downloadIdleTimeout = 50 * time.Millisecond
+	t.Cleanup(func() {
+		downloadIdleTimeout = idleTimeout
+	})
+	const offset = len(downloadTestContent) / 2
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// Send the start of the file, and stall until the request is canceled.
+			w.Header().Set("Content-Length", fmt.Sprint(len(downloadTestContent)))
+			_, _ = io.WriteString(w, downloadTestContent[:offset])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		if expected := fmt.Sprintf("bytes=%d-", offset); r.Header.Get("Range") != expected {
+			t.Errorf("expected Range %q but got %q", expected, r.Header.Get("Range"))
+		}
+		size := len(downloadTestContent)
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, size-1, size))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = io.WriteString(w, downloadTestContent[offset:])
+	}))
+	defer server.Close()
+	expectDownload(t, server.URL)
+	if requests != 2 {
+		t.Errorf("expected 2 requests, but got %d", requests)
+	}
+}
+
+func TestDownload_resumeRangeIgnored(t *testing.T) {
+	withDownloadRetryDelay(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			resetConnection(t, w, downloadTestContent[:len(downloadTestContent)/2])
+			return
+		}
+		if r.Header.Get("Range") == "" {
+			t.Error("expected a Range request")
+		}
+		// The server sends the whole file, whose start has already been read.
+		w.Header().Set("ETag", `"v1"`)
+		_, _ = io.WriteString(w, downloadTestContent)
+	}))
+	defer server.Close()
+	expectDownload(t, server.URL)
+	if requests != 2 {
+		t.Errorf("expected 2 requests, but got %d", requests)
+	}
+}
+
+func TestDownload_resumeFileChanged(t *testing.T) {
+	withDownloadRetryDelay(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			resetConnection(t, w, downloadTestContent[:len(downloadTestContent)/2])
+			return
+		}
+		w.Header().Set("ETag", `"v2"`)
+		_, _ = io.WriteString(w, strings.ToUpper(downloadTestContent))
+	}))
+	defer server.Close()
+	body, cleanup, err := newFileState().downloadBinary(context.Background(), server.URL)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(body); err == nil || !strings.Contains(err.Error(), "file changed during download") {
+		t.Errorf("expected file changed error, but got %v", err)
+	}
+}
+
+func TestDownload_maxAttempts(t *testing.T) {
+	withDownloadRetryDelay(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	_, cleanup, err := newFileState().downloadBinary(context.Background(), server.URL)
+	defer cleanup()
+	if err == nil || !strings.Contains(err.Error(), "status code 500") {
+		t.Errorf("expected status code error, but got %v", err)
+	}
+	if requests != maxDownloadAttempts {
+		t.Errorf("expected %d requests, but got %d", maxDownloadAttempts, requests)
+	}
+}
+
+func TestDownload_notFound(t *testing.T) {
+	withDownloadRetryDelay(t)
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	_, cleanup, err := newFileState().downloadBinary(context.Background(), server.URL)
+	defer cleanup()
+	if err == nil || !strings.Contains(err.Error(), "status code 404") {
+		t.Errorf("expected status code error, but got %v", err)
+	}
+	if requests != 1 {
+		t.Errorf("expected 1 request, but got %d", requests)
+	}
+}
+
+const downloadTestContent = "0123456789abcdefghijklmnopqrstuvwxyz0123456789abcdefghijklmnopqrstuvwxyz"
+
+func withDownloadRetryDelay(t *testing.T) {
+	t.Helper()
+	delay := downloadRetryDelay
+	downloadRetryDelay = time.Millisecond
+	t.Cleanup(func() {
+		downloadRetryDelay = delay
+	})
+}
+
+func expectDownload(t *testing.T, url string) {
+	t.Helper()
+	body, cleanup, err := newFileState().downloadBinary(context.Background(), url)
+	defer cleanup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != downloadTestContent {
+		t.Errorf("expected %q but got %q", downloadTestContent, data)
+	}
+}
+
+// resetConnection writes the start of the response with the content, before resetting the connection. When content
+// is empty, the connection is reset before the response. It's called by handlers, and reports errors with t.Error.
+func resetConnection(t *testing.T, w http.ResponseWriter, content string) {
+	t.Helper()
+	conn, buf, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer conn.Close()
+	if content != "" {
+		_, _ = fmt.Fprintf(buf, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\nETag: \"v1\"\r\n\r\n", len(downloadTestContent))
+		_, _ = io.WriteString(buf, content)
+		if err := buf.Flush(); err != nil {
+			t.Error(err)
+			return
+		}
+		// Give the client time to read the start of the response before the connection is reset.
+		time.Sleep(50 * time.Millisecond)
+	}
+	// Closing with a zero linger time resets the connection.
+	if err := conn.(*net.TCPConn).SetLinger(0); err != nil {
+		t.Error(err)
+	}
+}
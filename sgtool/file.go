@@ -3,13 +3,14 @@ package sgtool
 import (
 	"archive/tar"
 	"archive/zip"
+	"bufio"
 	"bytes"
+	"compress/bzip2"
 	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
 	"io"
-	"io/fs"
 	"net/http"
 	"os"
 	"path"
@@ -17,6 +18,8 @@ import (
 	"strings"
 
 	"go.einride.tech/sage/sg"
+	"go.einride.tech/sage/sgtool/internal/third_party/zstd"
+	"go.einride.tech/sage/sgtool/internal/xz"
 )
 
 type archiveType int
@@ -26,6 +29,13 @@ const (
 	Zip
 	Tar
 	TarGz
+	TarXz
+	TarBz2
+	TarZst
+	// Gz is a single gzip-compressed file.
+	Gz
+	// Auto detects the archive type of the file.
+	Auto
 )
 
 const (
@@ -47,6 +57,10 @@ type fileState struct {
 	skipFile     string
 	symlink      string
 	httpHeader   http.Header
+	// sha256 is the expected hex-encoded SHA-256 checksum of the file.
+	sha256        string
+	checksumURL   string
+	checksumEntry string
 }
 
 func newFileState() *fileState {
@@ -58,29 +72,38 @@ func newFileState() *fileState {
 
 // FromLocal can be used to work with local archive files.
 // HTTP related Options, such as WithHTTPHeader don't do anything here.
-func FromLocal(_ context.Context, filepath string, opts ...Opt) error {
+func FromLocal(ctx context.Context, filepath string, opts ...Opt) error {
 	s := newFileState()
 	for _, o := range opts {
 		o(s)
 	}
-	if s.skipFile != "" {
-		// Check if binary already exist
-		if _, err := os.Stat(s.skipFile); err == nil {
-			if s.symlink != "" {
-				if _, err := CreateSymlink(s.symlink); err != nil {
-					return err
-				}
-			}
-			return nil
-		}
+	if isDryRun(ctx, filepath) {
+		return nil
+	}
+	if skip, err := s.skipIfFileExists(); err != nil || skip {
+		return err
+	}
+	unlock, err := s.lock(ctx, filepath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// The tool may have been installed by another process while waiting for the lock.
+	if skip, err := s.skipIfFileExists(); err != nil || skip {
+		return err
+	}
+	if err := s.resolveChecksum(ctx); err != nil {
+		return err
 	}
-
 	f, err := os.Open(filepath)
 	if err != nil {
 		return fmt.Errorf("unable to open local file: %w", err)
 	}
 	defer f.Close()
-	return s.handleFileStream(f, path.Base(f.Name()))
+	if _, err := s.install(f, f.Name()); err != nil {
+		return err
+	}
+	return s.createSymlink()
 }
 
 func FromRemote(ctx context.Context, addr string, opts ...Opt) error {
@@ -88,64 +111,107 @@ func FromRemote(ctx context.Context, addr string, opts ...Opt) error {
 	for _, o := range opts {
 		o(s)
 	}
-	if s.skipFile != "" {
-		// Check if binary already exist
-		if _, err := os.Stat(s.skipFile); err == nil {
-			if s.symlink != "" {
-				if _, err := CreateSymlink(s.symlink); err != nil {
-					return err
-				}
-			}
-			return nil
+	if isDryRun(ctx, addr) {
+		return nil
+	}
+	if skip, err := s.skipIfFileExists(); err != nil || skip {
+		return err
+	}
+	if s.dstPath != "" {
+		if err := linkToolsCache(s.dstPath); err != nil {
+			return err
+		}
+		// The tool may already have been downloaded to the shared tools cache by another repository.
+		if skip, err := s.skipIfFileExists(); err != nil || skip {
+			return err
 		}
 	}
+	unlock, err := s.lock(ctx, addr)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// The tool may have been installed by another process while waiting for the lock.
+	if skip, err := s.skipIfFileExists(); err != nil || skip {
+		return err
+	}
+	if err := s.resolveChecksum(ctx); err != nil {
+		return err
+	}
+	if s.sha256 == "" {
+		checksum, err := lockedChecksum(ctx, addr)
+		if err != nil {
+			return err
+		}
+		s.sha256 = checksum
+	}
 	sg.Logger(ctx).Printf("fetching %s ...", addr)
 	rStream, cleanup, err := s.downloadBinary(ctx, addr)
 	if err != nil {
 		return fmt.Errorf("unable to download file: %w", err)
 	}
 	defer cleanup()
-	return s.handleFileStream(rStream, path.Base(addr))
+	checksum, err := s.install(rStream, addr)
+	if err != nil {
+		return err
+	}
+	if err := lockChecksum(ctx, s.dstPath, addr, checksum); err != nil {
+		return err
+	}
+	return s.createSymlink()
 }
 
-func (s *fileState) handleFileStream(inFile io.Reader, filename string) error {
-	if s.dstPath == "" {
-		return fmt.Errorf("destination directory is missing")
+// skipIfFileExists reports if the file given by WithSkipIfFileExists exists, in which case the symlink given by
+// WithSymlink is created.
+func (s *fileState) skipIfFileExists() (bool, error) {
+	if s.skipFile == "" {
+		return false, nil
 	}
-
-	f, err := os.Open(s.dstPath)
-	if err != nil {
-		if !errors.Is(err, fs.ErrNotExist) {
-			return fmt.Errorf("unable to read destination path")
-		}
-		if err := os.MkdirAll(s.dstPath, 0o755); err != nil {
-			return fmt.Errorf("unable to create destination path")
+	// Check if binary already exist
+	if _, err := os.Stat(s.skipFile); err == nil {
+		if err := s.createSymlink(); err != nil {
+			return false, err
 		}
+		return true, nil
 	}
-	f.Close()
+	return false, nil
+}
+
+// createSymlink creates the symlink given by WithSymlink, if any.
+func (s *fileState) createSymlink() error {
+	if s.symlink == "" {
+		return nil
+	}
+	_, err := CreateSymlink(s.symlink)
+	return err
+}
 
-	switch s.archiveType {
+// handleFileStream writes or extracts the file into dir.
+func (s *fileState) handleFileStream(inFile io.Reader, dir, filename string) error {
+	archiveType := s.archiveType
+	if archiveType == Auto {
+		buffered := bufio.NewReader(inFile)
+		// Files shorter than the header can't be archives with a header.
+		header, _ := buffered.Peek(archiveHeaderSize)
+		archiveType = detectArchiveType(header, filename)
+		inFile = buffered
+	}
+	switch archiveType {
 	case None:
-		// There should be only 1 entry in the map
-		if len(s.archiveFiles) > 1 {
-			return fmt.Errorf("only 1 destination file should be specified on direct downloads")
-		}
-		for _, v := range s.archiveFiles {
-			filename = v
-			break
+		if err := s.writeFile(inFile, dir, filename); err != nil {
+			return fmt.Errorf("unable to download remote file: %w", err)
 		}
-		out, err := os.OpenFile(filepath.Join(s.dstPath, filename), os.O_RDWR|os.O_CREATE, 0o755)
+	case Gz:
+		gzipStream, err := gzip.NewReader(inFile)
 		if err != nil {
-			return fmt.Errorf("unable to open %s: %w", filename, err)
+			return fmt.Errorf("unable to setup gzip stream: %w", err)
 		}
-		defer out.Close()
-		// write the body to file
-		_, err = io.Copy(out, inFile)
-		if err != nil {
-			return fmt.Errorf("unable to download remote file: %w", err)
+		defer gzipStream.Close()
+		if err := s.writeFile(gzipStream, dir, strings.TrimSuffix(filename, ".gz")); err != nil {
+			return fmt.Errorf("unable to gunzip the file: %w", err)
 		}
 	case Tar:
-		if err := s.extractTar(inFile); err != nil {
+		if err := s.extractTar(inFile, dir); err != nil {
 			return fmt.Errorf("unable to untar the file: %w", err)
 		}
 	case TarGz:
@@ -154,9 +220,25 @@ func (s *fileState) handleFileStream(inFile io.Reader, filename string) error {
 			return fmt.Errorf("unable to setup gzip stream: %w", err)
 		}
 		defer gzipStream.Close()
-		if err := s.extractTar(gzipStream); err != nil {
+		if err := s.extractTar(gzipStream, dir); err != nil {
 			return fmt.Errorf("unable to untarGz the file: %w", err)
 		}
+	case TarXz:
+		xzStream, err := xz.NewReader(inFile)
+		if err != nil {
+			return fmt.Errorf("unable to setup xz stream: %w", err)
+		}
+		if err := s.extractTar(xzStream, dir); err != nil {
+			return fmt.Errorf("unable to untarXz the file: %w", err)
+		}
+	case TarBz2:
+		if err := s.extractTar(bzip2.NewReader(inFile), dir); err != nil {
+			return fmt.Errorf("unable to untarBz2 the file: %w", err)
+		}
+	case TarZst:
+		if err := s.extractTar(zstd.NewReader(inFile), dir); err != nil {
+			return fmt.Errorf("unable to untarZst the file: %w", err)
+		}
 	case Zip:
 		// Zip archives require random access for reading, so we need to figure out the
 		// entire file size first by reading it completely
@@ -171,15 +253,10 @@ func (s *fileState) handleFileStream(inFile io.Reader, filename string) error {
 		if err != nil {
 			return fmt.Errorf("unable to unzip file: %w", err)
 		}
-		if _, err := s.extractZip(zipStream); err != nil {
+		if _, err := s.extractZip(zipStream, dir); err != nil {
 			return fmt.Errorf("unable to extract zip file: %w", err)
 		}
 	}
-	if s.symlink != "" {
-		if _, err := CreateSymlink(s.symlink); err != nil {
-			return err
-		}
-	}
 	return nil
 }
 
@@ -201,6 +278,40 @@ func WithUntarGz() Opt {
 	}
 }
 
+func WithUntarXz() Opt {
+	return func(f *fileState) {
+		f.archiveType = TarXz
+	}
+}
+
+func WithUntarBz2() Opt {
+	return func(f *fileState) {
+		f.archiveType = TarBz2
+	}
+}
+
+func WithUntarZst() Opt {
+	return func(f *fileState) {
+		f.archiveType = TarZst
+	}
+}
+
+// WithGunzip decompresses a single gzip-compressed file. The file is written without its .gz suffix, unless it's
+// renamed with WithRenameFile.
+func WithGunzip() Opt {
+	return func(f *fileState) {
+		f.archiveType = Gz
+	}
+}
+
+// WithAutoExtract detects the archive type of the file from its first bytes, or else from the suffix of its name,
+// and extracts it. Files that are not archives are written as is.
+func WithAutoExtract() Opt {
+	return func(f *fileState) {
+		f.archiveType = Auto
+	}
+}
+
 func WithDestinationDir(path string) Opt {
 	return func(f *fileState) {
 		f.dstPath = path
@@ -240,27 +351,29 @@ func WithHTTPHeader(key, value string) Opt {
 	}
 }
 
-func (s *fileState) downloadBinary(ctx context.Context, url string) (io.ReadCloser, func(), error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, func() {}, fmt.Errorf("download binary %s: %w", url, err)
+// writeFile writes a file that is not an archive into dir.
+func (s *fileState) writeFile(inFile io.Reader, dir, filename string) error {
+	// There should be only 1 entry in the map
+	if len(s.archiveFiles) > 1 {
+		return fmt.Errorf("only 1 destination file should be specified on direct downloads")
 	}
-
-	req.Header = s.httpHeader
-	//nolint:bodyclose // false positive due to cleanup function
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, func() {}, fmt.Errorf("download binary %s: %w", url, err)
+	for _, v := range s.archiveFiles {
+		filename = v
+		break
 	}
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, func() {}, fmt.Errorf("download binary %s: status code %d", url, resp.StatusCode)
+	out, err := os.OpenFile(filepath.Join(dir, filename), os.O_RDWR|os.O_CREATE, 0o755)
+	if err != nil {
+		return fmt.Errorf("unable to open %s: %w", filename, err)
 	}
-	return resp.Body, func() { resp.Body.Close() }, nil
+	defer out.Close()
+	//nolint:gosec // allow potential decompression bomb
+	_, err = io.Copy(out, inFile)
+	return err
 }
 
 // extractZip will decompress a zip archive from the given gzip.Reader into
-// the destination path.
-func (s *fileState) extractZip(reader *zip.Reader) ([]string, error) {
+// dir.
+func (s *fileState) extractZip(reader *zip.Reader, dir string) ([]string, error) {
 	filenames := make([]string, 0)
 	for _, f := range reader.File {
 		dstName := f.Name
@@ -270,10 +383,10 @@ func (s *fileState) extractZip(reader *zip.Reader) ([]string, error) {
 
 		// Store filename/path for returning and using later on
 		//nolint:gosec // allow file traversal when extracting archive
-		fpath := filepath.Join(s.dstPath, dstName)
+		fpath := filepath.Join(dir, dstName)
 
 		// Check for ZipSlip. More Info: http://bit.ly/2MsjAWE
-		if !strings.HasPrefix(fpath, filepath.Clean(s.dstPath)+string(os.PathSeparator)) {
+		if !strings.HasPrefix(fpath, filepath.Clean(dir)+string(os.PathSeparator)) {
 			return filenames, fmt.Errorf("%s: illegal file path", fpath)
 		}
 
@@ -289,8 +402,7 @@ func (s *fileState) extractZip(reader *zip.Reader) ([]string, error) {
 
 		// Some zip files do not contain folders as file entries.
 		// Make sure our parent dirs exists before we unzip.
-		dir := path.Dir(fpath)
-		err := os.MkdirAll(dir, os.ModePerm)
+		err := os.MkdirAll(path.Dir(fpath), os.ModePerm)
 		if err != nil {
 			return filenames, err
 		}
@@ -319,7 +431,7 @@ func (s *fileState) extractZip(reader *zip.Reader) ([]string, error) {
 	return filenames, nil
 }
 
-func (s *fileState) extractTar(reader io.Reader) error {
+func (s *fileState) extractTar(reader io.Reader, dir string) error {
 	if reader == nil {
 		return errors.New("unable to untar nil file")
 	}
@@ -339,7 +451,7 @@ func (s *fileState) extractTar(reader io.Reader) error {
 			dstName = name
 		}
 		//nolint:gosec // allow traversal into archive
-		path := filepath.Join(s.dstPath, dstName)
+		path := filepath.Join(dir, dstName)
 		if strings.Contains(path, "..") {
 			return fmt.Errorf("encountered .. inside tar filepath (%s). For security reasons, this is not allowed", path)
 		}
@@ -0,0 +1,171 @@
+package sgtool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.einride.tech/sage/sg"
+)
+
+// isDryRun reports if ctx is in dry-run mode, where tools are not installed, and logs the skipped install of source.
+func isDryRun(ctx context.Context, source string) bool {
+	if !sg.IsDryRun(ctx) {
+		return false
+	}
+	sg.Logger(ctx).Printf("dry run: install %s", source)
+	return true
+}
+
+// lockDir takes an advisory lock on the install of dir, a directory in the tools dir, which is shared by all
+// processes installing into dir, including other repositories sharing the tools cache. The returned function
+// releases the lock.
+func lockDir(ctx context.Context, dir string) (func(), error) {
+	target, err := resolveDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", dir, err)
+	}
+	return lockPath(ctx, target+".lock", "install of "+dir)
+}
+
+// lockPath takes an advisory lock on the file at path, which is created if it's missing, and logs that it's waiting
+// when another process holds the lock for the described operation. The returned function releases the lock.
+func lockPath(ctx context.Context, path, description string) (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	ok, err := tryLockFile(f)
+	if err == nil && !ok {
+		sg.Logger(ctx).Printf("waiting for another %s ...", description)
+		err = lockFile(f)
+	}
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("lock %s: %w", path, err)
+	}
+	return func() {
+		_ = unlockFile(f)
+		_ = f.Close()
+	}, nil
+}
+
+// resolveDir returns dir, or the directory it links to when it's a symlink, such as a link to the tools cache.
+func resolveDir(dir string) (string, error) {
+	info, err := os.Lstat(dir)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		return dir, nil
+	}
+	return filepath.EvalSymlinks(dir)
+}
+
+// lock takes the lock of the destination directory, or without a destination directory, the lock of the file name
+// of source in the tools dir.
+func (s *fileState) lock(ctx context.Context, source string) (func(), error) {
+	if s.dstPath == "" {
+		return lockPath(ctx, sg.FromToolsDir(path.Base(source)+".lock"), "install of "+source)
+	}
+	return lockDir(ctx, s.dstPath)
+}
+
+// install writes or extracts the file read from r into the destination directory, and returns its checksum.
+//
+// The file is extracted into a temporary directory next to the destination directory and verified, before it's
+// renamed into place. A failed or interrupted install leaves no files in the destination directory. Installs must
+// hold the lock of the destination directory.
+func (s *fileState) install(r io.Reader, source string) (string, error) {
+	if s.dstPath == "" {
+		return "", fmt.Errorf("install %s: destination directory is missing", source)
+	}
+	target, err := resolveDir(s.dstPath)
+	if err != nil {
+		return "", fmt.Errorf("install %s: %w", source, err)
+	}
+	tmpDir, err := installTempDir(target)
+	if err != nil {
+		return "", fmt.Errorf("install %s: %w", source, err)
+	}
+	defer os.RemoveAll(tmpDir)
+	checksum, err := s.extract(r, tmpDir, source)
+	if err != nil {
+		return "", err
+	}
+	if err := renameInto(tmpDir, target, s.skipEntry()); err != nil {
+		return "", fmt.Errorf("install %s: %w", source, err)
+	}
+	return checksum, nil
+}
+
+// installTempDir creates a temporary directory next to dir, to install into before renaming it into place. The
+// temporary directories of interrupted installs are removed.
+func installTempDir(dir string) (string, error) {
+	pattern := "." + filepath.Base(dir) + ".tmp-"
+	stale, _ := filepath.Glob(filepath.Join(filepath.Dir(dir), pattern+"*"))
+	for _, staleDir := range stale {
+		_ = os.RemoveAll(staleDir)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return "", err
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(dir), pattern)
+	if err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmpDir, 0o755); err != nil {
+		_ = os.RemoveAll(tmpDir)
+		return "", err
+	}
+	return tmpDir, nil
+}
+
+// skipEntry returns the entry of the destination directory containing the file given by WithSkipIfFileExists, if
+// any.
+func (s *fileState) skipEntry() string {
+	if s.skipFile == "" {
+		return ""
+	}
+	rel, err := filepath.Rel(s.dstPath, s.skipFile)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return ""
+	}
+	return strings.Split(filepath.ToSlash(rel), "/")[0]
+}
+
+// renameInto renames dir to dst, or when dst exists, renames each entry of dir into dst, replacing existing entries.
+//
+// The entry skipEntry is renamed last, so that an interrupted rename doesn't make the install look complete.
+func renameInto(dir, dst, skipEntry string) error {
+	if _, err := os.Lstat(dst); errors.Is(err, fs.ErrNotExist) {
+		return os.Rename(dir, dst)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Name() != skipEntry && entries[j].Name() == skipEntry
+	})
+	for _, entry := range entries {
+		to := filepath.Join(dst, entry.Name())
+		// Files are replaced by the rename, while directories have to be removed first.
+		if info, err := os.Lstat(to); err == nil && (info.IsDir() || entry.IsDir()) {
+			if err := os.RemoveAll(to); err != nil {
+				return err
+			}
+		}
+		if err := os.Rename(filepath.Join(dir, entry.Name()), to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
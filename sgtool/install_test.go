@@ -0,0 +1,183 @@
+package sgtool
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.einride.tech/sage/sg"
+)
+
+func TestFromLocal_concurrent(t *testing.T) {
+	withToolsCache(t)
+	archive := writeTestArchive(t, tarGz(t, map[string]string{"bin/tool": "tool"}))
+	dst := sg.FromToolsDir("tool", "1.0.0")
+	executable := filepath.Join(dst, "bin", "tool")
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := FromLocal(
+				context.Background(),
+				archive,
+				WithUntarGz(),
+				WithDestinationDir(dst),
+				WithSkipIfFileExists(executable),
+			); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if data, err := os.ReadFile(executable); err != nil || string(data) != "tool" {
+		t.Errorf("expected tool to be installed, but got %q, %v", data, err)
+	}
+	expectNoTempDirs(t, dst)
+}
+
+func TestFromLocal_interrupted(t *testing.T) {
+	withToolsCache(t)
+	data := tarGz(t, map[string]string{"bin/tool": strings.Repeat("tool", 1024)})
+	archive := writeTestArchive(t, data[:len(data)/2])
+	dst := sg.FromToolsDir("tool", "1.0.0")
+	if err := FromLocal(context.Background(), archive, WithUntarGz(), WithDestinationDir(dst)); err == nil {
+		t.Fatal("expected error for truncated archive")
+	}
+	if entries, err := os.ReadDir(dst); err == nil && len(entries) > 0 {
+		t.Errorf("expected no files in %s, but got %d", dst, len(entries))
+	}
+	expectNoTempDirs(t, dst)
+}
+
+func TestFileState_lockWithoutDestinationDir(t *testing.T) {
+	withToolsCache(t)
+	unlock, err := newFileState().lock(context.Background(), "https://example.com/tool.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock()
+}
+
+func TestInstallTempDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "tool")
+	stale := filepath.Join(filepath.Dir(dir), ".tool.tmp-123")
+	if err := os.MkdirAll(filepath.Join(stale, "bin"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	tmpDir, err := installTempDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(tmpDir) != filepath.Dir(dir) || !strings.HasPrefix(filepath.Base(tmpDir), ".tool.tmp-") {
+		t.Errorf("expected temporary directory next to %s, but got %s", dir, tmpDir)
+	}
+	if info, err := os.Stat(tmpDir); err != nil || info.Mode().Perm() != 0o755 {
+		t.Errorf("expected temporary directory with mode 0755, but got %v", err)
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("expected stale temporary directory to be removed, but got %v", err)
+	}
+}
+
+func TestRenameInto(t *testing.T) {
+	t.Run("new", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+		writeTestFiles(t, src, map[string]string{"bin/tool": "new"})
+		if err := renameInto(src, dst, ""); err != nil {
+			t.Fatal(err)
+		}
+		expectTestFile(t, filepath.Join(dst, "bin", "tool"), "new")
+		if _, err := os.Stat(src); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be renamed, but got %v", src, err)
+		}
+	})
+	t.Run("existing", func(t *testing.T) {
+		dir := t.TempDir()
+		src, dst := filepath.Join(dir, "src"), filepath.Join(dir, "dst")
+		writeTestFiles(t, dst, map[string]string{"bin/tool": "old", "bin/old": "old", "LICENSE": "old", "keep": "old"})
+		writeTestFiles(t, src, map[string]string{"bin/tool": "new", "LICENSE": "new"})
+		if err := renameInto(src, dst, "bin"); err != nil {
+			t.Fatal(err)
+		}
+		expectTestFile(t, filepath.Join(dst, "bin", "tool"), "new")
+		expectTestFile(t, filepath.Join(dst, "LICENSE"), "new")
+		expectTestFile(t, filepath.Join(dst, "keep"), "old")
+		if _, err := os.Stat(filepath.Join(dst, "bin", "old")); !os.IsNotExist(err) {
+			t.Errorf("expected replaced directory to be removed, but got %v", err)
+		}
+	})
+}
+
+func expectNoTempDirs(t *testing.T, dir string) {
+	t.Helper()
+	target, err := resolveDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpDirs, err := filepath.Glob(filepath.Join(filepath.Dir(target), ".*.tmp-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tmpDirs) > 0 {
+		t.Errorf("expected no temporary directories, but got %v", tmpDirs)
+	}
+}
+
+func tarGz(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func writeTestArchive(t *testing.T, data []byte) string {
+	t.Helper()
+	archive := filepath.Join(t.TempDir(), "tool.tar.gz")
+	if err := os.WriteFile(archive, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return archive
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		file := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expectTestFile(t *testing.T, file, expected string) {
+	t.Helper()
+	if data, err := os.ReadFile(file); err != nil || string(data) != expected {
+		t.Errorf("expected %s to contain %q, but got %q, %v", file, expected, data, err)
+	}
+}
@@ -0,0 +1,390 @@
+package xz
+
+import (
+	"errors"
+)
+
+// errCorrupt is returned for corrupt LZMA data.
+var errCorrupt = errors.New("xz: corrupt LZMA2 data")
+
+const (
+	numStates          = 12
+	numPosBitsMax      = 4
+	numLenToPosStates  = 4
+	numAlignBits       = 4
+	startPosModelIndex = 4
+	endPosModelIndex   = 14
+	numFullDistances   = 1 << (endPosModelIndex >> 1)
+	matchMinLen        = 2
+	probInit           = 1 << 10
+)
+
+// rangeDecoder decodes the range coded data of an LZMA chunk.
+type rangeDecoder struct {
+	in   []byte
+	pos  int
+	rng  uint32
+	code uint32
+}
+
+func (rc *rangeDecoder) init(in []byte) error {
+	if len(in) < 5 || in[0] != 0 {
+		return errCorrupt
+	}
+	rc.in = in
+	rc.pos = 5
+	rc.rng = 0xFFFFFFFF
+	rc.code = uint32(in[1])<<24 | uint32(in[2])<<16 | uint32(in[3])<<8 | uint32(in[4])
+	if rc.code == rc.rng {
+		return errCorrupt
+	}
+	return nil
+}
+
+func (rc *rangeDecoder) normalize() {
+	if rc.rng < 1<<24 {
+		rc.rng <<= 8
+		rc.code <<= 8
+		// Reading past the end of the chunk is detected by finished.
+		if rc.pos < len(rc.in) {
+			rc.code |= uint32(rc.in[rc.pos])
+		}
+		rc.pos++
+	}
+}
+
+// finished reports if the chunk was decoded completely.
+func (rc *rangeDecoder) finished() bool {
+	return rc.pos == len(rc.in) && rc.code == 0
+}
+
+func (rc *rangeDecoder) decodeBit(prob *uint16) uint32 {
+	bound := (rc.rng >> 11) * uint32(*prob)
+	var bit uint32
+	if rc.code < bound {
+		*prob += ((1 << 11) - *prob) >> 5
+		rc.rng = bound
+	} else {
+		*prob -= *prob >> 5
+		rc.code -= bound
+		rc.rng -= bound
+		bit = 1
+	}
+	rc.normalize()
+	return bit
+}
+
+func (rc *rangeDecoder) decodeDirectBits(numBits int) uint32 {
+	var result uint32
+	for ; numBits > 0; numBits-- {
+		rc.rng >>= 1
+		var bit uint32
+		if rc.code >= rc.rng {
+			rc.code -= rc.rng
+			bit = 1
+		}
+		rc.normalize()
+		result = result<<1 | bit
+	}
+	return result
+}
+
+func (rc *rangeDecoder) decodeBitTree(probs []uint16, numBits int) uint32 {
+	m := uint32(1)
+	for i := 0; i < numBits; i++ {
+		m = m<<1 | rc.decodeBit(&probs[m])
+	}
+	return m - 1<<numBits
+}
+
+func (rc *rangeDecoder) decodeReverseBitTree(probs []uint16, numBits int) uint32 {
+	m := uint32(1)
+	var symbol uint32
+	for i := 0; i < numBits; i++ {
+		bit := rc.decodeBit(&probs[m])
+		m = m<<1 | bit
+		symbol |= bit << i
+	}
+	return symbol
+}
+
+// lenDecoder decodes match lengths.
+type lenDecoder struct {
+	choice  uint16
+	choice2 uint16
+	low     [1 << numPosBitsMax][1 << 3]uint16
+	mid     [1 << numPosBitsMax][1 << 3]uint16
+	high    [1 << 8]uint16
+}
+
+func (d *lenDecoder) reset() {
+	d.choice = probInit
+	d.choice2 = probInit
+	resetProbs(d.high[:])
+	for i := range d.low {
+		resetProbs(d.low[i][:])
+		resetProbs(d.mid[i][:])
+	}
+}
+
+func (d *lenDecoder) decode(rc *rangeDecoder, posState uint32) uint32 {
+	if rc.decodeBit(&d.choice) == 0 {
+		return rc.decodeBitTree(d.low[posState][:], 3)
+	}
+	if rc.decodeBit(&d.choice2) == 0 {
+		return 1<<3 + rc.decodeBitTree(d.mid[posState][:], 3)
+	}
+	return 1<<4 + rc.decodeBitTree(d.high[:], 8)
+}
+
+// lzmaDecoder decodes LZMA chunks of an LZMA2 stream.
+type lzmaDecoder struct {
+	lc, lp, pb uint32
+	literal    []uint16
+	posSlot    [numLenToPosStates][1 << 6]uint16
+	posSpecial [1 + numFullDistances - endPosModelIndex]uint16
+	align      [1 << numAlignBits]uint16
+	isMatch    [numStates << numPosBitsMax]uint16
+	isRep      [numStates]uint16
+	isRepG0    [numStates]uint16
+	isRepG1    [numStates]uint16
+	isRepG2    [numStates]uint16
+	isRep0Long [numStates << numPosBitsMax]uint16
+	lenDec     lenDecoder
+	repLenDec  lenDecoder
+	state      uint32
+	reps       [4]uint32
+}
+
+// setProperties sets the lc, lp and pb properties, encoded as (pb * 5 + lp) * 9 + lc.
+func (d *lzmaDecoder) setProperties(props byte) error {
+	if props >= 9*5*5 {
+		return errCorrupt
+	}
+	lc := uint32(props % 9)
+	props /= 9
+	lp := uint32(props % 5)
+	pb := uint32(props / 5)
+	// LZMA2 limits lc + lp to 4.
+	if lc+lp > 4 {
+		return errCorrupt
+	}
+	d.lc, d.lp, d.pb = lc, lp, pb
+	d.literal = make([]uint16, 0x300<<(lc+lp))
+	return nil
+}
+
+func (d *lzmaDecoder) reset() {
+	resetProbs(d.literal)
+	for i := range d.posSlot {
+		resetProbs(d.posSlot[i][:])
+	}
+	resetProbs(d.posSpecial[:])
+	resetProbs(d.align[:])
+	resetProbs(d.isMatch[:])
+	resetProbs(d.isRep[:])
+	resetProbs(d.isRepG0[:])
+	resetProbs(d.isRepG1[:])
+	resetProbs(d.isRepG2[:])
+	resetProbs(d.isRep0Long[:])
+	d.lenDec.reset()
+	d.repLenDec.reset()
+	d.state = 0
+	d.reps = [4]uint32{}
+}
+
+func resetProbs(probs []uint16) {
+	for i := range probs {
+		probs[i] = probInit
+	}
+}
+
+// decode decodes size bytes of the LZMA chunk in into the window.
+func (d *lzmaDecoder) decode(w *window, in []byte, size int) error {
+	var rc rangeDecoder
+	if err := rc.init(in); err != nil {
+		return err
+	}
+	pbMask := uint32(1)<<d.pb - 1
+	end := w.total + int64(size)
+	for w.total < end {
+		posState := uint32(w.total) & pbMask
+		if rc.decodeBit(&d.isMatch[d.state<<numPosBitsMax+posState]) == 0 {
+			d.decodeLiteral(&rc, w)
+			continue
+		}
+		var length uint32
+		if rc.decodeBit(&d.isRep[d.state]) == 0 {
+			length = d.lenDec.decode(&rc, posState)
+			if d.state < 7 {
+				d.state = 7
+			} else {
+				d.state = 10
+			}
+			dist := d.decodeDistance(&rc, length)
+			d.reps = [4]uint32{dist, d.reps[0], d.reps[1], d.reps[2]}
+		} else {
+			if !w.has(d.reps[0]) {
+				return errCorrupt
+			}
+			if rc.decodeBit(&d.isRepG0[d.state]) == 0 {
+				if rc.decodeBit(&d.isRep0Long[d.state<<numPosBitsMax+posState]) == 0 {
+					// Short rep, a single byte at rep0.
+					if d.state < 7 {
+						d.state = 9
+					} else {
+						d.state = 11
+					}
+					w.putByte(w.byteAt(d.reps[0] + 1))
+					continue
+				}
+			} else {
+				var dist uint32
+				if rc.decodeBit(&d.isRepG1[d.state]) == 0 {
+					dist = d.reps[1]
+				} else {
+					if rc.decodeBit(&d.isRepG2[d.state]) == 0 {
+						dist = d.reps[2]
+					} else {
+						dist = d.reps[3]
+						d.reps[3] = d.reps[2]
+					}
+					d.reps[2] = d.reps[1]
+				}
+				d.reps[1] = d.reps[0]
+				d.reps[0] = dist
+			}
+			length = d.repLenDec.decode(&rc, posState)
+			if d.state < 7 {
+				d.state = 8
+			} else {
+				d.state = 11
+			}
+		}
+		length += matchMinLen
+		if !w.has(d.reps[0]) || int64(length) > end-w.total {
+			return errCorrupt
+		}
+		w.copyMatch(d.reps[0]+1, int(length))
+	}
+	if !rc.finished() {
+		return errCorrupt
+	}
+	return nil
+}
+
+func (d *lzmaDecoder) decodeLiteral(rc *rangeDecoder, w *window) {
+	var prevByte uint32
+	if w.has(0) {
+		prevByte = uint32(w.byteAt(1))
+	}
+	lpMask := uint32(1)<<d.lp - 1
+	litState := (uint32(w.total)&lpMask)<<d.lc + prevByte>>(8-d.lc)
+	probs := d.literal[0x300*litState : 0x300*(litState+1)]
+	symbol := uint32(1)
+	if d.state >= 7 {
+		matchByte := uint32(w.byteAt(d.reps[0] + 1))
+		for symbol < 0x100 {
+			matchBit := (matchByte >> 7) & 1
+			matchByte <<= 1
+			bit := rc.decodeBit(&probs[(1+matchBit)<<8+symbol])
+			symbol = symbol<<1 | bit
+			if matchBit != bit {
+				break
+			}
+		}
+	}
+	for symbol < 0x100 {
+		symbol = symbol<<1 | rc.decodeBit(&probs[symbol])
+	}
+	w.putByte(byte(symbol))
+	switch {
+	case d.state < 4:
+		d.state = 0
+	case d.state < 10:
+		d.state -= 3
+	default:
+		d.state -= 6
+	}
+}
+
+// decodeDistance decodes the distance of a match of length matchMinLen + length, minus one.
+func (d *lzmaDecoder) decodeDistance(rc *rangeDecoder, length uint32) uint32 {
+	lenState := length
+	if lenState > numLenToPosStates-1 {
+		lenState = numLenToPosStates - 1
+	}
+	posSlot := rc.decodeBitTree(d.posSlot[lenState][:], 6)
+	if posSlot < startPosModelIndex {
+		return posSlot
+	}
+	numDirectBits := int(posSlot>>1) - 1
+	dist := (2 | posSlot&1) << numDirectBits
+	if posSlot < endPosModelIndex {
+		return dist + rc.decodeReverseBitTree(d.posSpecial[dist-posSlot:], numDirectBits)
+	}
+	dist += rc.decodeDirectBits(numDirectBits-numAlignBits) << numAlignBits
+	return dist + rc.decodeReverseBitTree(d.align[:], numAlignBits)
+}
+
+// window is the dictionary of an LZMA2 stream, which keeps the last decoded bytes for matches, and the decoded bytes
+// that haven't been read yet.
+type window struct {
+	buf  []byte
+	size int
+	pos  int
+	// total is the number of bytes decoded since the last dictionary reset.
+	total int64
+	// out are the decoded bytes that haven't been read yet.
+	out []byte
+}
+
+func newWindow(size int) *window {
+	return &window{size: size}
+}
+
+func (w *window) reset() {
+	w.buf = w.buf[:0]
+	w.pos = 0
+	w.total = 0
+}
+
+// has reports if the window contains the byte at distance dist + 1.
+func (w *window) has(dist uint32) bool {
+	return int64(dist) < int64(len(w.buf))
+}
+
+// byteAt returns the byte at distance dist, which must be at least 1.
+func (w *window) byteAt(dist uint32) byte {
+	i := w.pos - int(dist)
+	if i < 0 {
+		i += len(w.buf)
+	}
+	return w.buf[i]
+}
+
+func (w *window) putByte(b byte) {
+	if len(w.buf) < w.size {
+		w.buf = append(w.buf, b)
+	} else {
+		w.buf[w.pos] = b
+	}
+	w.pos++
+	if w.pos == w.size {
+		w.pos = 0
+	}
+	w.total++
+	w.out = append(w.out, b)
+}
+
+func (w *window) copyMatch(dist uint32, length int) {
+	for ; length > 0; length-- {
+		w.putByte(w.byteAt(dist))
+	}
+}
+
+func (w *window) write(p []byte) {
+	for _, b := range p {
+		w.putByte(b)
+	}
+}
@@ -0,0 +1,101 @@
+package xz
+
+import (
+	"errors"
+	"io"
+)
+
+// lzma2Decoder decodes an LZMA2 stream, chunk by chunk.
+type lzma2Decoder struct {
+	r              io.Reader
+	window         *window
+	lzma           lzmaDecoder
+	in             []byte
+	needDictReset  bool
+	needProperties bool
+}
+
+func newLZMA2Decoder(r io.Reader, dictSize int) *lzma2Decoder {
+	return &lzma2Decoder{
+		r:              r,
+		window:         newWindow(dictSize),
+		needDictReset:  true,
+		needProperties: true,
+	}
+}
+
+// decodeChunk decodes the next chunk into the output of the window, and reports if the end of the stream was reached.
+func (d *lzma2Decoder) decodeChunk() (bool, error) {
+	var header [6]byte
+	if _, err := io.ReadFull(d.r, header[:1]); err != nil {
+		return false, noEOF(err)
+	}
+	control := header[0]
+	if control == 0x00 {
+		return true, nil
+	}
+	if control >= 0xE0 || control == 0x01 {
+		d.needProperties = true
+		d.needDictReset = false
+		d.window.reset()
+	} else if d.needDictReset {
+		return false, errCorrupt
+	}
+	if control < 0x80 {
+		// Uncompressed chunk.
+		if control > 0x02 {
+			return false, errCorrupt
+		}
+		if _, err := io.ReadFull(d.r, header[1:3]); err != nil {
+			return false, noEOF(err)
+		}
+		size := (int(header[1])<<8 | int(header[2])) + 1
+		if err := d.readInput(size); err != nil {
+			return false, err
+		}
+		d.window.write(d.in)
+		return false, nil
+	}
+	headerSize := 5
+	if control >= 0xC0 {
+		headerSize = 6
+	}
+	if _, err := io.ReadFull(d.r, header[1:headerSize]); err != nil {
+		return false, noEOF(err)
+	}
+	unpackedSize := (int(control&0x1F)<<16 | int(header[1])<<8 | int(header[2])) + 1
+	packedSize := (int(header[3])<<8 | int(header[4])) + 1
+	switch {
+	case control >= 0xC0:
+		if err := d.lzma.setProperties(header[5]); err != nil {
+			return false, err
+		}
+		d.needProperties = false
+		d.lzma.reset()
+	case d.needProperties:
+		return false, errCorrupt
+	case control >= 0xA0:
+		d.lzma.reset()
+	}
+	if err := d.readInput(packedSize); err != nil {
+		return false, err
+	}
+	return false, d.lzma.decode(d.window, d.in, unpackedSize)
+}
+
+func (d *lzma2Decoder) readInput(size int) error {
+	if cap(d.in) < size {
+		d.in = make([]byte, size)
+	}
+	d.in = d.in[:size]
+	_, err := io.ReadFull(d.r, d.in)
+	return noEOF(err)
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF, for reads of data that must exist.
+func noEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
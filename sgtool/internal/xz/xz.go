@@ -0,0 +1,406 @@
+// Package xz provides a decompressor for xz streams, as created by the xz command.
+//
+// Only the LZMA2 filter is supported, which is the filter of xz streams unless other filters are chosen explicitly.
+package xz
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+)
+
+const (
+	// filterLZMA2 is the ID of the LZMA2 filter.
+	filterLZMA2 = 0x21
+	// maxDictSize limits the dictionary size of LZMA2 streams. The xz command uses at most 64 MiB.
+	maxDictSize = 1 << 30
+)
+
+//nolint:gochecknoglobals
+var (
+	headerMagic = []byte{0xFD, '7', 'z', 'X', 'Z', 0x00}
+	footerMagic = []byte{'Y', 'Z'}
+	crc64Table  = crc64.MakeTable(crc64.ECMA)
+)
+
+// errFormat is returned for data that is not a valid xz stream.
+var errFormat = errors.New("xz: invalid format")
+
+// Reader decompresses an xz stream.
+type Reader struct {
+	r *countingReader
+	// flags are the stream flags of the current stream.
+	flags [2]byte
+	// block is the current block, if any.
+	block *blockReader
+	// records are the unpadded and uncompressed sizes of the blocks of the current stream.
+	records [][2]int64
+	out     []byte
+	err     error
+}
+
+// NewReader creates a new Reader reading the xz stream from r.
+func NewReader(r io.Reader) (*Reader, error) {
+	z := &Reader{r: &countingReader{r: bufio.NewReader(r)}}
+	if err := z.readStreamHeader(); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// Read implements io.Reader.
+func (z *Reader) Read(p []byte) (int, error) {
+	for len(z.out) == 0 {
+		if z.err != nil {
+			return 0, z.err
+		}
+		z.err = z.next()
+	}
+	n := copy(p, z.out)
+	z.out = z.out[n:]
+	return n, nil
+}
+
+// next decodes the next chunk of the current block, or reads the next block, index or stream.
+func (z *Reader) next() error {
+	if z.block == nil {
+		return z.readBlockHeader()
+	}
+	z.block.dec.window.out = z.block.dec.window.out[:0]
+	end, err := z.block.dec.decodeChunk()
+	if err != nil {
+		return err
+	}
+	z.out = z.block.dec.window.out
+	if z.block.check != nil {
+		z.block.check.Write(z.out)
+	}
+	z.block.uncompressedSize += int64(len(z.out))
+	if end {
+		return z.finishBlock()
+	}
+	return nil
+}
+
+func (z *Reader) readStreamHeader() error {
+	var header [12]byte
+	if _, err := io.ReadFull(z.r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errFormat
+		}
+		return noEOF(err)
+	}
+	if !bytes.Equal(header[:6], headerMagic) {
+		return errFormat
+	}
+	if crc32.ChecksumIEEE(header[6:8]) != binary.LittleEndian.Uint32(header[8:]) {
+		return errors.New("xz: stream header checksum mismatch")
+	}
+	if header[6] != 0 || header[7] > 0x0F {
+		return errors.New("xz: unsupported stream flags")
+	}
+	copy(z.flags[:], header[6:8])
+	z.records = z.records[:0]
+	return nil
+}
+
+// blockReader reads a block of an xz stream.
+type blockReader struct {
+	dec              *lzma2Decoder
+	check            hash.Hash
+	start            int64
+	headerSize       int64
+	compressedSize   int64
+	uncompressedSize int64
+	// expectedCompressedSize and expectedUncompressedSize are the sizes in the block header, or -1.
+	expectedCompressedSize   int64
+	expectedUncompressedSize int64
+}
+
+func (z *Reader) readBlockHeader() error {
+	start := z.r.n
+	sizeByte, err := z.r.ReadByte()
+	if err != nil {
+		return noEOF(err)
+	}
+	if sizeByte == 0 {
+		return z.readIndex()
+	}
+	header := make([]byte, (int(sizeByte)+1)*4)
+	header[0] = sizeByte
+	if _, err := io.ReadFull(z.r, header[1:]); err != nil {
+		return noEOF(err)
+	}
+	content, checksum := header[:len(header)-4], header[len(header)-4:]
+	if crc32.ChecksumIEEE(content) != binary.LittleEndian.Uint32(checksum) {
+		return errors.New("xz: block header checksum mismatch")
+	}
+	flags := content[1]
+	if flags&0x3C != 0 {
+		return errors.New("xz: unsupported block flags")
+	}
+	block := &blockReader{
+		start:                    start,
+		headerSize:               int64(len(header)),
+		check:                    newCheck(z.flags[1]),
+		expectedCompressedSize:   -1,
+		expectedUncompressedSize: -1,
+	}
+	fields := bytes.NewReader(content[2:])
+	if flags&0x40 != 0 {
+		if block.expectedCompressedSize, err = readVarint(fields); err != nil {
+			return err
+		}
+	}
+	if flags&0x80 != 0 {
+		if block.expectedUncompressedSize, err = readVarint(fields); err != nil {
+			return err
+		}
+	}
+	numFilters := int(flags&0x03) + 1
+	var dictSize int
+	for i := 0; i < numFilters; i++ {
+		id, err := readVarint(fields)
+		if err != nil {
+			return err
+		}
+		propsSize, err := readVarint(fields)
+		if err != nil {
+			return err
+		}
+		if id != filterLZMA2 || i != numFilters-1 {
+			return fmt.Errorf("xz: unsupported filter %#x", id)
+		}
+		if propsSize != 1 {
+			return errFormat
+		}
+		props, err := fields.ReadByte()
+		if err != nil {
+			return errFormat
+		}
+		if dictSize, err = lzma2DictSize(props); err != nil {
+			return err
+		}
+	}
+	// The rest of the header is padding.
+	for fields.Len() > 0 {
+		if b, _ := fields.ReadByte(); b != 0 {
+			return errFormat
+		}
+	}
+	block.dec = newLZMA2Decoder(z.r, dictSize)
+	z.block = block
+	return nil
+}
+
+// finishBlock reads the padding and the check of the current block.
+func (z *Reader) finishBlock() error {
+	block := z.block
+	z.block = nil
+	block.compressedSize = z.r.n - block.start - block.headerSize
+	if block.expectedCompressedSize >= 0 && block.compressedSize != block.expectedCompressedSize ||
+		block.expectedUncompressedSize >= 0 && block.uncompressedSize != block.expectedUncompressedSize {
+		return errors.New("xz: block size mismatch")
+	}
+	for (z.r.n-block.start)%4 != 0 {
+		b, err := z.r.ReadByte()
+		if err != nil {
+			return noEOF(err)
+		}
+		if b != 0 {
+			return errFormat
+		}
+	}
+	checkSize := checkSize(z.flags[1])
+	checksum := make([]byte, checkSize)
+	if _, err := io.ReadFull(z.r, checksum); err != nil {
+		return noEOF(err)
+	}
+	if block.check != nil && !bytes.Equal(checksum, checkSum(block.check)) {
+		return errors.New("xz: checksum mismatch")
+	}
+	unpaddedSize := block.headerSize + block.compressedSize + int64(checkSize)
+	z.records = append(z.records, [2]int64{unpaddedSize, block.uncompressedSize})
+	return nil
+}
+
+// readIndex reads the index of the current stream, whose indicator has been read, and the stream footer.
+func (z *Reader) readIndex() error {
+	start := z.r.n - 1
+	r := &hashReader{r: z.r, h: crc32.NewIEEE()}
+	r.h.Write([]byte{0})
+	numRecords, err := readVarint(r)
+	if err != nil {
+		return err
+	}
+	if numRecords != int64(len(z.records)) {
+		return errors.New("xz: index mismatch")
+	}
+	for _, record := range z.records {
+		unpaddedSize, err := readVarint(r)
+		if err != nil {
+			return err
+		}
+		uncompressedSize, err := readVarint(r)
+		if err != nil {
+			return err
+		}
+		if unpaddedSize != record[0] || uncompressedSize != record[1] {
+			return errors.New("xz: index mismatch")
+		}
+	}
+	for (z.r.n-start)%4 != 0 {
+		b, err := r.ReadByte()
+		if err != nil {
+			return noEOF(err)
+		}
+		if b != 0 {
+			return errFormat
+		}
+	}
+	// The index ends with its checksum.
+	indexSize := z.r.n - start + 4
+	var footer [16]byte
+	if _, err := io.ReadFull(z.r, footer[:]); err != nil {
+		return noEOF(err)
+	}
+	if binary.LittleEndian.Uint32(footer[:4]) != r.h.Sum32() {
+		return errors.New("xz: index checksum mismatch")
+	}
+	// The stream footer follows the checksum of the index.
+	stream := footer[4:]
+	if crc32.ChecksumIEEE(stream[4:10]) != binary.LittleEndian.Uint32(stream[:4]) {
+		return errors.New("xz: stream footer checksum mismatch")
+	}
+	if (int64(binary.LittleEndian.Uint32(stream[4:8]))+1)*4 != indexSize ||
+		!bytes.Equal(stream[8:10], z.flags[:]) || !bytes.Equal(stream[10:12], footerMagic) {
+		return errFormat
+	}
+	return z.readNextStream()
+}
+
+// readNextStream skips stream padding and reads the header of the next stream, or returns io.EOF at the end of the
+// input.
+func (z *Reader) readNextStream() error {
+	for {
+		padding, err := z.r.r.Peek(4)
+		if len(padding) == 0 && errors.Is(err, io.EOF) {
+			return io.EOF
+		}
+		if err != nil {
+			return noEOF(err)
+		}
+		if !bytes.Equal(padding, []byte{0, 0, 0, 0}) {
+			return z.readStreamHeader()
+		}
+		_, _ = z.r.Read(padding)
+	}
+}
+
+func newCheck(checkType byte) hash.Hash {
+	switch checkType {
+	case 0x01:
+		return crc32.NewIEEE()
+	case 0x04:
+		return crc64.New(crc64Table)
+	case 0x0A:
+		return sha256.New()
+	default:
+		// Other check types are skipped without verification.
+		return nil
+	}
+}
+
+// checkSum returns the checksum of h as stored in xz streams, where CRCs are little-endian.
+func checkSum(h hash.Hash) []byte {
+	sum := h.Sum(nil)
+	switch h.(type) {
+	case hash.Hash32, hash.Hash64:
+		for i, j := 0, len(sum)-1; i < j; i, j = i+1, j-1 {
+			sum[i], sum[j] = sum[j], sum[i]
+		}
+	}
+	return sum
+}
+
+func checkSize(checkType byte) int {
+	if checkType == 0 {
+		return 0
+	}
+	return 4 << ((checkType - 1) / 3)
+}
+
+// lzma2DictSize returns the dictionary size of the LZMA2 filter properties.
+func lzma2DictSize(props byte) (int, error) {
+	if props > 40 {
+		return 0, errFormat
+	}
+	if props == 40 {
+		return maxDictSize, nil
+	}
+	size := (2 | int64(props)&1) << (props/2 + 11)
+	if size > maxDictSize {
+		return maxDictSize, nil
+	}
+	return int(size), nil
+}
+
+// readVarint reads a variable-length integer of up to 9 bytes.
+func readVarint(r io.ByteReader) (int64, error) {
+	var value uint64
+	for i := 0; i < 9; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, errFormat
+		}
+		value |= uint64(b&0x7F) << (7 * i)
+		if b&0x80 == 0 {
+			if b == 0 && i > 0 {
+				return 0, errFormat
+			}
+			return int64(value), nil
+		}
+	}
+	return 0, errFormat
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+func (r *countingReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.n++
+	}
+	return b, err
+}
+
+// hashReader hashes the bytes read from r.
+type hashReader struct {
+	r io.ByteReader
+	h hash.Hash32
+}
+
+func (r *hashReader) ReadByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err == nil {
+		r.h.Write([]byte{b})
+	}
+	return b, err
+}
@@ -0,0 +1,111 @@
+package xz
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		files    []string
+		expected []string
+	}{
+		{
+			name:     "default",
+			files:    []string{"input.txt.xz"},
+			expected: []string{"input.txt"},
+		},
+		{
+			name:     "crc32 and small dictionary",
+			files:    []string{"input.txt.crc32.xz"},
+			expected: []string{"input.txt"},
+		},
+		{
+			name:     "sha256 and multiple blocks",
+			files:    []string{"input.txt.sha256.xz"},
+			expected: []string{"input.txt"},
+		},
+		{
+			name:     "no check",
+			files:    []string{"input.txt.none.xz"},
+			expected: []string{"input.txt"},
+		},
+		{
+			name:     "uncompressed chunks",
+			files:    []string{"random.bin.xz"},
+			expected: []string{"random.bin"},
+		},
+		{
+			name:     "concatenated streams",
+			files:    []string{"input.txt.xz", "random.bin.xz"},
+			expected: []string{"input.txt", "random.bin"},
+		},
+	} {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			input := readTestdata(t, tt.files...)
+			r, err := NewReader(bytes.NewReader(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := readTestdata(t, tt.expected...); !bytes.Equal(actual, expected) {
+				t.Errorf("expected %d decompressed bytes to match, but got %d different bytes", len(expected), len(actual))
+			}
+		})
+	}
+}
+
+func TestReader_streamPadding(t *testing.T) {
+	input := readTestdata(t, "input.txt.xz")
+	input = append(input, make([]byte, 8)...)
+	r, err := NewReader(bytes.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReader_corrupt(t *testing.T) {
+	input := readTestdata(t, "input.txt.xz")
+	for _, offset := range []int{0, 8, 12, 100, len(input) / 2, len(input) - 30, len(input) - 2} {
+		corrupt := append([]byte(nil), input...)
+		corrupt[offset] ^= 0x55
+		r, err := NewReader(bytes.NewReader(corrupt))
+		if err == nil {
+			_, err = io.Copy(io.Discard, r)
+		}
+		if err == nil {
+			t.Errorf("expected error for corrupt byte at offset %d", offset)
+		}
+	}
+	r, err := NewReader(bytes.NewReader(input[:len(input)-1]))
+	if err == nil {
+		_, err = io.Copy(io.Discard, r)
+	}
+	if err == nil {
+		t.Error("expected error for truncated input")
+	}
+}
+
+func readTestdata(t *testing.T, names ...string) []byte {
+	t.Helper()
+	var result []byte
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join("testdata", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, data...)
+	}
+	return result
+}
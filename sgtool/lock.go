@@ -0,0 +1,204 @@
+package sgtool
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"go.einride.tech/sage/sg"
+)
+
+// Lock is the tool lockfile of a repository, which records the checksum of each tool downloaded with FromRemote.
+//
+// Downloads with a checksum in the lockfile are refused when the checksum doesn't match. Downloads without a
+// checksum in the lockfile are added to it.
+//
+// Checksums are recorded for the OS and architecture of the host that downloads a tool, and downloads are only
+// verified against the checksum of their own OS and architecture. Each platform is locked the first time a tool is
+// downloaded on it, so commit the lockfile after running the tools on every platform that should be locked.
+type Lock struct {
+	// Tools are the downloaded tool artifacts, sorted by name, version, OS, architecture and URL.
+	Tools []LockedTool `json:"tools"`
+}
+
+// LockedTool is a tool artifact downloaded for an OS and architecture.
+type LockedTool struct {
+	// Name of the tool.
+	Name string `json:"name"`
+	// Version of the tool, if it's known.
+	Version string `json:"version,omitempty"`
+	// OS the artifact was downloaded for.
+	OS string `json:"os"`
+	// Arch is the architecture the artifact was downloaded for.
+	Arch string `json:"arch"`
+	// URL of the artifact.
+	URL string `json:"url"`
+	// SHA256 is the hex-encoded SHA-256 checksum of the artifact.
+	SHA256 string `json:"sha256"`
+}
+
+// LockPath returns the path of the tool lockfile.
+func LockPath() string {
+	return sg.FromSageDir("sage.lock")
+}
+
+// ReadLock reads the tool lockfile at path. A missing lockfile is empty.
+func ReadLock(path string) (*Lock, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Lock{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read lock: %w", err)
+	}
+	var lock Lock
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, fmt.Errorf("read lock %s: %w", path, err)
+	}
+	return &lock, nil
+}
+
+// Write the tool lockfile to path.
+func (l *Lock) Write(path string) error {
+	sort.Slice(l.Tools, func(i, j int) bool {
+		a, b := l.Tools[i], l.Tools[j]
+		return strings.Join([]string{a.Name, a.Version, a.OS, a.Arch, a.URL}, "\x00") <
+			strings.Join([]string{b.Name, b.Version, b.OS, b.Arch, b.URL}, "\x00")
+	})
+	data, err := json.MarshalIndent(l, "", "  ")
+	if err != nil {
+		return fmt.Errorf("write lock: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write lock: %w", err)
+	}
+	return nil
+}
+
+// Lookup returns the locked artifact of the URL for the OS and architecture.
+func (l *Lock) Lookup(url, goos, goarch string) (LockedTool, bool) {
+	for _, tool := range l.Tools {
+		if tool.URL == url && tool.OS == goos && tool.Arch == goarch {
+			return tool, true
+		}
+	}
+	return LockedTool{}, false
+}
+
+// add adds a locked artifact, replacing the same artifact and other versions of the tool for the OS and
+// architecture.
+func (l *Lock) add(locked LockedTool) {
+	tools := l.Tools[:0]
+	for _, tool := range l.Tools {
+		sameArtifact := tool.URL == locked.URL
+		otherVersion := tool.Name == locked.Name && tool.Version != locked.Version
+		if tool.OS == locked.OS && tool.Arch == locked.Arch && (sameArtifact || otherVersion) {
+			continue
+		}
+		tools = append(tools, tool)
+	}
+	l.Tools = append(tools, locked)
+}
+
+// UpdateLock updates the tool lockfile with update, holding a lock that serializes updates of the lockfile by all
+// processes. The lockfile is not written when update returns an error.
+func UpdateLock(ctx context.Context, update func(*Lock) error) error {
+	unlock, err := lockLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	lock, err := ReadLock(LockPath())
+	if err != nil {
+		return err
+	}
+	if err := update(lock); err != nil {
+		return err
+	}
+	return lock.Write(LockPath())
+}
+
+// lockLock takes the lock of the tool lockfile. The lock is kept in the build dir, which is not committed.
+func lockLock(ctx context.Context) (func(), error) {
+	return lockPath(ctx, sg.FromBuildDir("sage.lock.lock"), "update of "+LockPath())
+}
+
+// lockedChecksum returns the locked checksum of the URL for the host OS and architecture.
+func lockedChecksum(ctx context.Context, url string) (string, error) {
+	unlock, err := lockLock(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+	lock, err := ReadLock(LockPath())
+	if err != nil {
+		return "", err
+	}
+	if tool, ok := lock.Lookup(url, runtime.GOOS, runtime.GOARCH); ok {
+		return tool.SHA256, nil
+	}
+	return "", nil
+}
+
+// lockChecksum records the checksum of the URL for the host OS and architecture, for the tool installed in dstPath.
+func lockChecksum(ctx context.Context, dstPath, url, checksum string) error {
+	unlock, err := lockLock(ctx)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	lock, err := ReadLock(LockPath())
+	if err != nil {
+		return err
+	}
+	if tool, ok := lock.Lookup(url, runtime.GOOS, runtime.GOARCH); ok && tool.SHA256 == checksum {
+		return nil
+	}
+	name, version := toolNameVersion(dstPath, url)
+	lock.add(LockedTool{
+		Name:    name,
+		Version: version,
+		OS:      runtime.GOOS,
+		Arch:    runtime.GOARCH,
+		URL:     url,
+		SHA256:  checksum,
+	})
+	return lock.Write(LockPath())
+}
+
+// toolNameVersion returns the name and version of the tool installed in dstPath, which by convention is
+// FromToolsDir(name, version), or a subdirectory of it.
+func toolNameVersion(dstPath, url string) (string, string) {
+	rel, err := filepath.Rel(sg.FromToolsDir(), dstPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return filepath.Base(url), ""
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) == 1 {
+		return parts[0], ""
+	}
+	return parts[0], parts[1]
+}
+
+// Checksum downloads the artifact at url and returns its hex-encoded SHA-256 checksum.
+func Checksum(ctx context.Context, url string) (string, error) {
+	body, cleanup, err := newFileState().downloadBinary(ctx, url)
+	defer cleanup()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, body); err != nil {
+		return "", fmt.Errorf("checksum %s: %w", url, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,61 @@
+package sgtool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestUpdateLock(t *testing.T) {
+	withToolsCache(t)
+	ctx := context.Background()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			url := fmt.Sprintf("https://example.com/tool-%d.tar.gz", i)
+			if err := lockChecksum(ctx, "tool", url, fmt.Sprintf("%064d", i)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	lock, err := ReadLock(LockPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lock.Tools) != 10 {
+		t.Fatalf("expected 10 locked tools, but got %d", len(lock.Tools))
+	}
+	checksum, err := lockedChecksum(ctx, "https://example.com/tool-3.tar.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := fmt.Sprintf("%064d", 3); checksum != expected {
+		t.Errorf("expected checksum %s but got %s", expected, checksum)
+	}
+	info, err := os.Stat(LockPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o644 {
+		t.Errorf("expected lockfile mode 0644, but got %v", info.Mode().Perm())
+	}
+	// The lockfile is not written when the update fails.
+	errUpdate := errors.New("update failed")
+	if err := UpdateLock(ctx, func(lock *Lock) error {
+		lock.Tools = nil
+		return errUpdate
+	}); !errors.Is(err, errUpdate) {
+		t.Fatalf("expected update error, but got %v", err)
+	}
+	if lock, err := ReadLock(LockPath()); err != nil || len(lock.Tools) != 10 {
+		t.Errorf("expected the lockfile to be kept, but got %v, %v", lock, err)
+	}
+}
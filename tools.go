@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"go.einride.tech/sage/sg"
+	"go.einride.tech/sage/sgtool"
+)
+
+// listTools prints the tools in the tool lockfile.
+func listTools(ctx context.Context) {
+	lock, err := sgtool.ReadLock(sgtool.LockPath())
+	if err != nil {
+		sg.Logger(ctx).Fatal(err)
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "NAME\tVERSION\tPLATFORM\tSHA256\tURL")
+	for _, tool := range lock.Tools {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s/%s\t%s\t%s\n", tool.Name, tool.Version, tool.OS, tool.Arch, tool.SHA256, tool.URL)
+	}
+	if err := tw.Flush(); err != nil {
+		sg.Logger(ctx).Fatal(err)
+	}
+}
+
+// verifyTools downloads the tools in the tool lockfile and verifies their checksums.
+func verifyTools(ctx context.Context) {
+	lock, err := sgtool.ReadLock(sgtool.LockPath())
+	if err != nil {
+		sg.Logger(ctx).Fatal(err)
+	}
+	var failed bool
+	for _, tool := range lock.Tools {
+		checksum, err := sgtool.Checksum(ctx, tool.URL)
+		switch {
+		case err != nil:
+			failed = true
+			sg.Logger(ctx).Printf("%s %s (%s/%s): %v", tool.Name, tool.Version, tool.OS, tool.Arch, err)
+		case checksum != tool.SHA256:
+			failed = true
+			sg.Logger(ctx).Printf(
+				"%s %s (%s/%s): checksum mismatch, expected sha256 %s but got %s",
+				tool.Name, tool.Version, tool.OS, tool.Arch, tool.SHA256, checksum,
+			)
+		default:
+			sg.Logger(ctx).Printf("%s %s (%s/%s): ok", tool.Name, tool.Version, tool.OS, tool.Arch)
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// lockTools downloads the tools in the tool lockfile and verifies their checksums. Changed checksums, e.g. of a
+// re-released tool, are only recorded with update.
+func lockTools(ctx context.Context, update bool) {
+	lock, err := sgtool.ReadLock(sgtool.LockPath())
+	if err != nil {
+		sg.Logger(ctx).Fatal(err)
+	}
+	checksums := make(map[sgtool.LockedTool]string)
+	var changed bool
+	for _, tool := range lock.Tools {
+		checksum, err := sgtool.Checksum(ctx, tool.URL)
+		if err != nil {
+			sg.Logger(ctx).Fatal(err)
+		}
+		switch {
+		case checksum == tool.SHA256:
+			continue
+		case update:
+			sg.Logger(ctx).Printf("%s %s (%s/%s): updated checksum", tool.Name, tool.Version, tool.OS, tool.Arch)
+			checksums[tool] = checksum
+		default:
+			changed = true
+			sg.Logger(ctx).Printf(
+				"%s %s (%s/%s): checksum changed, expected sha256 %s but got %s",
+				tool.Name, tool.Version, tool.OS, tool.Arch, tool.SHA256, checksum,
+			)
+		}
+	}
+	if changed {
+		sg.Logger(ctx).Fatal("checksums changed, run tools lock -update to record them")
+	}
+	if len(checksums) == 0 {
+		return
+	}
+	// Only the checksums that were verified are updated, in case the lockfile was updated in the meantime.
+	if err := sgtool.UpdateLock(ctx, func(lock *sgtool.Lock) error {
+		for i, tool := range lock.Tools {
+			if checksum, ok := checksums[tool]; ok {
+				lock.Tools[i].SHA256 = checksum
+			}
+		}
+		return nil
+	}); err != nil {
+		sg.Logger(ctx).Fatal(err)
+	}
+}
@@ -80,11 +80,11 @@ func Run(ctx context.Context, args ...string) error {
 			return err
 		}
 		// TODO: Investigate why this call sometimes fails with context timeout.
-		if err := retryMaxTimes(3, func() error {
+		if err := sg.WithRetry(func(ctx context.Context) error {
 			bufBuildCmd := sgbuf.Command(ctx, "build", "-o", descriptorFile)
 			bufBuildCmd.Dir = moduleDir
 			return bufBuildCmd.Run()
-		}); err != nil {
+		}, 3, 0).Run(ctx); err != nil {
 			return err
 		}
 		cmd := Command(
@@ -118,15 +118,12 @@ func Run(ctx context.Context, args ...string) error {
 				if err != nil {
 					return err
 				}
-				// Put the file reference on a new line to help GitHub Actions detect the lint error.
-				sg.Logger(ctx).Printf(
-					"\n%s:%d:%d: %s (%s)",
-					relativeFilePath,
-					p.Location.Start.Line,
-					p.Location.Start.Column,
-					strings.TrimSuffix(p.Message, "."),
-					p.RuleID,
-				)
+				sg.LogAnnotation(ctx, sg.Annotation{
+					File:    relativeFilePath,
+					Line:    p.Location.Start.Line,
+					Column:  p.Location.Start.Column,
+					Message: fmt.Sprintf("%s (%s)", strings.TrimSuffix(p.Message, "."), p.RuleID),
+				})
 			}
 		}
 		return nil
@@ -178,14 +175,3 @@ type result struct {
 		RuleDocURI string `json:"rule_doc_uri"`
 	} `json:"problems"`
 }
-
-func retryMaxTimes(n int, fn func() error) error {
-	var err error
-	for i := 0; i < n; i++ {
-		err = fn()
-		if err == nil {
-			break
-		}
-	}
-	return err
-}
@@ -25,6 +25,8 @@ func NpmAuthenticate(ctx context.Context, packageJSONDir, registryURL string) er
 	if err := cmd.Run(); err != nil {
 		return err
 	}
+	accessToken := strings.TrimSpace(accessTokenOutput.String())
+	ctx = sg.ContextWithSecrets(ctx, accessToken)
 
 	registry := strings.TrimPrefix(registryURL, "https://")
 	// Trailing slashes at the end of the URL have been known to cause issues with some setups
@@ -68,7 +70,7 @@ func NpmAuthenticate(ctx context.Context, packageJSONDir, registryURL string) er
 			"-L",
 			"user",
 			fmt.Sprintf("//%s/:_authToken", registry),
-			strings.TrimSpace(accessTokenOutput.String()),
+			accessToken,
 		)
 		cmd.Dir = packageJSONDir
 		if err := cmd.Run(); err != nil {
@@ -83,7 +85,7 @@ func NpmAuthenticate(ctx context.Context, packageJSONDir, registryURL string) er
 			"set",
 			"--home",
 			fmt.Sprintf(`npmRegistries["//%s"].npmAuthToken`, registry),
-			strings.TrimSpace(accessTokenOutput.String()),
+			accessToken,
 		)
 		cmd.Dir = packageJSONDir
 		return cmd.Run()
@@ -9,7 +9,6 @@ import (
 
 	"go.einride.tech/sage/sg"
 	"go.einride.tech/sage/sgtool"
-	"go.einride.tech/sage/tools/sgxz"
 )
 
 const (
@@ -37,7 +36,6 @@ func Run(ctx context.Context, apply bool) error {
 }
 
 func PrepareCommand(ctx context.Context) error {
-	sg.Deps(ctx, sgxz.PrepareCommand)
 	toolDir := sg.FromToolsDir(name)
 	binDir := filepath.Join(toolDir, version, "bin")
 	binary := filepath.Join(binDir, name)
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"unicode"
@@ -41,6 +42,10 @@ func PrepareCommand(ctx context.Context) error {
 		binURL,
 		sgtool.WithDestinationDir(toolDir),
 		sgtool.WithUntarGz(),
+		sgtool.WithChecksumFile(
+			fmt.Sprintf("https://github.com/bufbuild/buf/releases/download/v%s/sha256.txt", version),
+			path.Base(binURL),
+		),
 		sgtool.WithSkipIfFileExists(binary),
 		sgtool.WithSymlink(binary),
 	); err != nil {
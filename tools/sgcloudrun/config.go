@@ -56,10 +56,11 @@ func DevelopCommand(ctx context.Context, path, keyFile, configFile string) (*exe
 	if err != nil {
 		return nil, err
 	}
-	env, err := resolveEnvFromConfigFile(ctx, configFile, key.ProjectID, accessToken)
+	env, secrets, err := resolveEnvFromConfigFile(ctx, configFile, key.ProjectID, accessToken)
 	if err != nil {
 		return nil, err
 	}
+	ctx = sg.ContextWithSecrets(ctx, append(secrets, accessToken)...)
 	cmd := sg.Command(ctx, "go", "run", path)
 	cmd.Env = append(cmd.Env, "K_REVISION=local"+sggit.SHA(ctx))
 	cmd.Env = append(cmd.Env, "K_CONFIGURATION="+configFile)
@@ -82,16 +83,30 @@ func LocalDevelop(ctx context.Context, path, configFile, projectID, serviceAccou
 // The environment variables are returned on the format KEY=value and can easily be outputted to a .env file or similar.
 // NOTE: this function creates a temporary creds-xxxxx.json file that is meant to be removed when the service is shut
 // down. Make sure to call CleanUpLocalDevelop after shutting down the service.
+// NOTE: the environment variables contain the values of the secrets referenced by the config file. Use
+// LocalDevelopCommand, or sg.ContextWithSecrets, to redact them from the output of commands.
 func LocalDevelopEnv(
 	ctx context.Context,
 	configFile string,
 	projectID string,
 	serviceAccountEmail string,
 ) ([]string, error) {
+	env, _, err := localDevelopEnv(ctx, configFile, projectID, serviceAccountEmail)
+	return env, err
+}
+
+// localDevelopEnv returns the environment variables of LocalDevelopEnv, and the secret values among them, including
+// the access token of the service account.
+func localDevelopEnv(
+	ctx context.Context,
+	configFile string,
+	projectID string,
+	serviceAccountEmail string,
+) ([]string, []string, error) {
 	// Grab the local user token to impersonate the service account
 	currentADC, err := applicationDefaultCredentials()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	// Store a local token wrapping the user token in metadata to make a delegated request.
@@ -115,26 +130,26 @@ func LocalDevelopEnv(
 	}
 	delegateCredsJSON, err := json.Marshal(delegateCreds)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	accessToken, err := fetchImpersonatedAccessToken(ctx, serviceAccountEmail)
 	if err != nil {
-		return nil, fmt.Errorf("unable to fetch impersonated service account access token: %v", err)
+		return nil, nil, fmt.Errorf("unable to fetch impersonated service account access token: %v", err)
 	}
 
-	env, err := resolveEnvFromConfigFile(ctx, configFile, projectID, accessToken)
+	env, secrets, err := resolveEnvFromConfigFile(ctx, configFile, projectID, accessToken)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	workDir := sg.FromBuildDir("gcloud")
 	if err := os.MkdirAll(workDir, 0o755); err != nil {
-		return nil, fmt.Errorf("unable to create path to store gcloud credentials: %v", err)
+		return nil, nil, fmt.Errorf("unable to create path to store gcloud credentials: %v", err)
 	}
 	credsPath := filepath.Join(workDir, fmt.Sprintf("creds-%s.json", randomLower(5)))
 	if err := os.WriteFile(credsPath, delegateCredsJSON, 0o600); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	env = append(env, "K_REVISION=local"+sggit.SHA(ctx))
@@ -142,7 +157,7 @@ func LocalDevelopEnv(
 	env = append(env, "GOOGLE_CLOUD_PROJECT="+projectID)
 	env = append(env, "GOOGLE_APPLICATION_CREDENTIALS="+credsPath)
 
-	return env, nil
+	return env, append(secrets, accessToken), nil
 }
 
 // CleanUpLocalDevelop is meant to be called after the Cloud Run service is shut down locally.
@@ -165,16 +180,19 @@ func LocalDevelopCommand(
 	projectID string,
 	serviceAccountEmail string,
 ) (*exec.Cmd, error) {
-	env, err := LocalDevelopEnv(ctx, configFile, projectID, serviceAccountEmail)
+	env, secrets, err := localDevelopEnv(ctx, configFile, projectID, serviceAccountEmail)
 	if err != nil {
 		return nil, err
 	}
+	ctx = sg.ContextWithSecrets(ctx, secrets...)
 
 	cmd := sg.Command(ctx, "go", "run", path)
 	cmd.Env = append(cmd.Env, env...)
 	cmd.Env = append(cmd.Env, os.Environ()...) // allow environment overrides
+	// Interrupt the process group of go run, which includes the service, before cleaning up.
+	cancel := cmd.Cancel
 	cmd.Cancel = func() error {
-		if err := cmd.Process.Kill(); err != nil {
+		if err := cancel(); err != nil {
 			return err
 		}
 		return CleanUpLocalDevelop(cmd.Env)
@@ -182,7 +200,10 @@ func LocalDevelopCommand(
 	return cmd, nil
 }
 
-func resolveEnvFromConfigFile(ctx context.Context, filename, project, accessToken string) (_ []string, err error) {
+func resolveEnvFromConfigFile(
+	ctx context.Context,
+	filename, project, accessToken string,
+) (_ []string, secrets []string, err error) {
 	defer func() {
 		if err != nil {
 			err = fmt.Errorf("resolve env from YAML service specification file %s: %w", filename, err)
@@ -190,14 +211,14 @@ func resolveEnvFromConfigFile(ctx context.Context, filename, project, accessToke
 	}()
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	cmd := sgyq.Command(ctx, "-o", "json")
 	cmd.Stdin = bytes.NewReader(data)
 	var output bytes.Buffer
 	cmd.Stdout = &output
 	if err := cmd.Run(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	var config struct {
 		Metadata struct {
@@ -223,10 +244,10 @@ func resolveEnvFromConfigFile(ctx context.Context, filename, project, accessToke
 		}
 	}
 	if err := json.NewDecoder(&output).Decode(&config); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	if len(config.Spec.Template.Spec.Containers) != 1 {
-		return nil, fmt.Errorf("unexpected number of containers: %d", len(config.Spec.Template.Spec.Containers))
+		return nil, nil, fmt.Errorf("unexpected number of containers: %d", len(config.Spec.Template.Spec.Containers))
 	}
 	result := make([]string, 0, 100)
 	if config.Metadata.Name != "" {
@@ -246,12 +267,13 @@ func resolveEnvFromConfigFile(ctx context.Context, filename, project, accessToke
 				env.ValueFrom.SecretKeyRef.Key,
 			)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			result = append(result, env.Name+"="+secret)
+			secrets = append(secrets, secret)
 		}
 	}
-	return result, nil
+	return result, secrets, nil
 }
 
 func printServiceAccountAccessToken(ctx context.Context, serviceAccount, keyFile string) (_ string, err error) {
@@ -22,6 +22,9 @@ const (
 )
 
 // RunEmulator runs the Cloud Spanner emulator in Docker.
+//
+// The emulator address is set in the SPANNER_EMULATOR_HOST environment variable of the process, so targets running
+// the emulator from parallel dependencies should hold the same lock, see sg.WithLock.
 func RunEmulator(ctx context.Context) (_ func(), err error) {
 	defer func() {
 		if err != nil {
@@ -8,6 +8,7 @@ import (
 	"io/fs"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 
@@ -25,7 +26,7 @@ var DefaultConfig []byte
 
 func Command(ctx context.Context, args ...string) *exec.Cmd {
 	sg.Deps(ctx, PrepareCommand)
-	return sg.Command(ctx, sg.FromBinDir(name), args...)
+	return sg.Command(sg.WithAnnotationRules(ctx, sg.GCCAnnotationRule), sg.FromBinDir(name), args...)
 }
 
 func defaultConfigPath() string {
@@ -112,6 +113,14 @@ func PrepareCommand(ctx context.Context) error {
 		binURL,
 		sgtool.WithDestinationDir(binDir),
 		sgtool.WithUntarGz(),
+		sgtool.WithChecksumFile(
+			fmt.Sprintf(
+				"https://github.com/golangci/golangci-lint/releases/download/v%s/golangci-lint-%s-checksums.txt",
+				version,
+				version,
+			),
+			path.Base(binURL),
+		),
 		sgtool.WithRenameFile(fmt.Sprintf("%s/golangci-lint", golangciLint), name),
 		sgtool.WithSkipIfFileExists(binary),
 		sgtool.WithSymlink(binary),
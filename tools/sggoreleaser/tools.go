@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
@@ -53,6 +54,10 @@ func PrepareCommand(ctx context.Context) error {
 		sgtool.WithSkipIfFileExists(binary),
 		sgtool.WithSymlink(binary),
 		sgtool.WithUntarGz(),
+		sgtool.WithChecksumFile(
+			fmt.Sprintf("https://github.com/goreleaser/goreleaser/releases/download/v%s/checksums.txt", version),
+			path.Base(binURL),
+		),
 	); err != nil {
 		return fmt.Errorf("unable to download %s: %w", name, err)
 	}
@@ -8,7 +8,9 @@ import (
 	"fmt"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
 
 	"go.einride.tech/sage/sg"
@@ -21,9 +23,31 @@ const version = "2.12.1-beta"
 //nolint:gochecknoglobals
 var commandPath string
 
+//nolint:gochecknoglobals
+var annotationRegexp = regexp.MustCompile(`^(.+?):(\d+) (\S+) (error|warning|info|style): (.+)$`)
+
 func Command(ctx context.Context, args ...string) *exec.Cmd {
 	sg.Deps(ctx, PrepareCommand)
-	return sg.Command(ctx, commandPath, args...)
+	return sg.Command(sg.WithAnnotationRules(ctx, AnnotationRule), commandPath, args...)
+}
+
+// AnnotationRule parses the default output format of hadolint, path:line rule severity: message.
+func AnnotationRule(line string) (sg.Annotation, bool) {
+	match := annotationRegexp.FindStringSubmatch(line)
+	if match == nil {
+		return sg.Annotation{}, false
+	}
+	result := sg.Annotation{File: match[1], Message: fmt.Sprintf("%s (%s)", match[5], match[3])}
+	result.Line, _ = strconv.Atoi(match[2])
+	switch match[4] {
+	case "error":
+		result.Level = sg.AnnotationError
+	case "warning":
+		result.Level = sg.AnnotationWarning
+	default:
+		result.Level = sg.AnnotationNotice
+	}
+	return result, true
 }
 
 func Run(ctx context.Context) error {
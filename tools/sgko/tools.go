@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 	"unicode"
@@ -42,6 +43,10 @@ func PrepareCommand(ctx context.Context) error {
 		binURL,
 		sgtool.WithDestinationDir(binDir),
 		sgtool.WithUntarGz(),
+		sgtool.WithChecksumFile(
+			fmt.Sprintf("https://github.com/google/ko/releases/download/v%s/checksums.txt", version),
+			path.Base(binURL),
+		),
 		sgtool.WithSkipIfFileExists(binary),
 		sgtool.WithSymlink(binary),
 	); err != nil {
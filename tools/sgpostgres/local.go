@@ -25,6 +25,9 @@ const (
 //
 // Primary goal is to have a shared local instance for test runs. Heavily inspired by
 // Spanner emulator, tools/sgcloudspanner/emulator.go .
+//
+// The instance URL is set in the POSTGRES_URL environment variable of the process, so targets running a local
+// instance from parallel dependencies should hold the same lock, see sg.WithLock.
 func RunLocal(
 	ctx context.Context,
 	databaseName string,
@@ -2,17 +2,14 @@ package sgshellcheck
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"io/fs"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 
 	"go.einride.tech/sage/sg"
 	"go.einride.tech/sage/sgtool"
-	"go.einride.tech/sage/tools/sgxz"
 )
 
 const (
@@ -22,7 +19,8 @@ const (
 
 func Command(ctx context.Context, args ...string) *exec.Cmd {
 	sg.Deps(ctx, PrepareCommand)
-	return sg.Command(ctx, sg.FromBinDir(name), args...)
+	// Problems are reported as annotations when run with --format=gcc, as Run does.
+	return sg.Command(sg.WithAnnotationRules(ctx, sg.GCCAnnotationRule), sg.FromBinDir(name), args...)
 }
 
 // Run shellcheck on all files ending with .sh and .bash in the repo.
@@ -43,11 +41,10 @@ func Run(ctx context.Context) error {
 	}); err != nil {
 		return err
 	}
-	return Command(ctx, inputFiles...).Run()
+	return Command(ctx, append([]string{"--format=gcc"}, inputFiles...)...).Run()
 }
 
 func PrepareCommand(ctx context.Context) error {
-	sg.Deps(ctx, sgxz.PrepareCommand)
 	const binaryName = "shellcheck"
 	toolDir := sg.FromToolsDir(binaryName)
 	binDir := filepath.Join(toolDir, version, "bin")
@@ -66,34 +63,16 @@ func PrepareCommand(ctx context.Context) error {
 		version,
 		fmt.Sprintf("%s.%s.%s.tar.xz", shellcheck, hostOS, hostArch),
 	)
-
-	decompressedArchive := filepath.Join(toolDir, fmt.Sprintf("%s.%s.%s.tar", shellcheck, hostOS, hostArch))
-	compressedArchive := decompressedArchive + ".xz"
-
 	if err := sgtool.FromRemote(
 		ctx,
 		binURL,
-		sgtool.WithDestinationDir(toolDir),
-		sgtool.WithSkipIfFileExists(binary),
-	); err != nil {
-		return fmt.Errorf("unable to download %s: %w", binaryName, err)
-	}
-	// Manual check for existence since we can't use sgtool here
-	if _, err := os.Stat(binary); errors.Is(err, os.ErrNotExist) {
-		if err := sgxz.Command(ctx, "-d", compressedArchive).Run(); err != nil {
-			return fmt.Errorf("unable to decompress %s: %w", compressedArchive, err)
-		}
-	}
-	if err := sgtool.FromLocal(
-		ctx,
-		decompressedArchive,
-		sgtool.WithUntar(),
-		sgtool.WithSkipIfFileExists(binary),
 		sgtool.WithDestinationDir(binDir),
-		sgtool.WithSymlink(binary),
+		sgtool.WithUntarXz(),
 		sgtool.WithRenameFile(fmt.Sprintf("%s/shellcheck", shellcheck), binaryName),
+		sgtool.WithSkipIfFileExists(binary),
+		sgtool.WithSymlink(binary),
 	); err != nil {
-		return fmt.Errorf("unable to extract %s: %w", decompressedArchive, err)
+		return fmt.Errorf("unable to download %s: %w", binaryName, err)
 	}
-	return os.RemoveAll(decompressedArchive)
+	return nil
 }
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
 	"runtime"
 
@@ -87,6 +88,14 @@ func PrepareCommand(ctx context.Context) error {
 		binURL,
 		sgtool.WithDestinationDir(toolDir),
 		sgtool.WithUntarGz(),
+		sgtool.WithChecksumFile(
+			fmt.Sprintf(
+				"https://github.com/aquasecurity/trivy/releases/download/v%s/trivy_%s_checksums.txt",
+				version,
+				version,
+			),
+			path.Base(binURL),
+		),
 		sgtool.WithSkipIfFileExists(binary),
 		sgtool.WithSymlink(binary),
 	); err != nil {